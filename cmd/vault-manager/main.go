@@ -2,23 +2,26 @@ package main
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/app-sre/vault-manager/pkg/state"
+	"github.com/app-sre/vault-manager/pkg/status"
 	"github.com/app-sre/vault-manager/pkg/utils"
 	"github.com/app-sre/vault-manager/pkg/vault"
 	"github.com/app-sre/vault-manager/toplevel"
-	"github.com/machinebox/graphql"
-	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
-	"gopkg.in/yaml.v2"
 
 	// Register top-level configurations.
 	_ "github.com/app-sre/vault-manager/toplevel/audit"
@@ -71,23 +74,112 @@ func (a ByPriority) Swap(i, j int) {
 	a[i], a[j] = a[j], a[i]
 }
 
+// options of a reconcile run set by flags
+type options struct {
+	dryRun         bool
+	runOnce        bool
+	kubeAuth       bool
+	threadPoolSize int
+	planOutput     string
+	// maximum number of instances reconciled concurrently
+	instanceConcurrency int
+	// maximum duration of the reconcile of a single instance, unlimited when zero
+	instanceTimeout time.Duration
+	// maximum duration of a single request to a vault instance, unlimited when zero
+	requestTimeout time.Duration
+	// how long the running configurations may take to finish after a termination signal
+	shutdownGracePeriod time.Duration
+}
+
 func main() {
 	defer logFile.Close()
 
-	var dryRun bool
-	var runOnce bool
-	var kubeAuth bool
-	var threadPoolSize int
-	flag.BoolVar(&dryRun, "dry-run", false, "If true, will only print planned actions")
-	flag.IntVar(&threadPoolSize, "thread-pool-size", 10, "Some operations are running in parallel"+
+	var opts options
+	var configSource string
+	var referenceChecks string
+	var deletionBudget string
+	var allowMassDeletion bool
+	var ownership string
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "If true, will only print planned actions")
+	flag.IntVar(&opts.threadPoolSize, "thread-pool-size", 10, "Some operations are running in parallel"+
 		" to achieve the best performance, so -thread-pool-size determine how many threads can be utilized, default is 10")
-	flag.BoolVar(&runOnce, "run-once", true, "If true, program will skip loop and exit after first reconcile attempt")
-	flag.BoolVar(&kubeAuth, "kube-auth", false, "If true, will attempt to utilize kubernetes authentication where applicable")
+	flag.BoolVar(&opts.runOnce, "run-once", true, "If true, program will skip loop and exit after first reconcile attempt")
+	flag.BoolVar(&opts.kubeAuth, "kube-auth", false, "If true, will attempt to utilize kubernetes authentication where applicable")
+	flag.StringVar(&opts.planOutput, "plan-output", "", "If set, planned changes across all instances are written as JSON to this file")
+	flag.StringVar(&configSource, "config-source", "graphql", "Source of the desired state, one of `graphql`,"+
+		" `file:<dir>` to read YAML/JSON files from a local directory, an `http(s)://` url or `stdin`")
+	flag.StringVar(&referenceChecks, "reference-checks", string(toplevel.ReferencesFail), "Whether references to"+
+		" policies, auth backends or kv engines that are not declared for an instance `fail` the run or only `warn`")
+	flag.IntVar(&opts.instanceConcurrency, "instance-concurrency", 4, "Maximum number of vault instances reconciled concurrently")
+	flag.DurationVar(&opts.instanceTimeout, "instance-timeout", 0, "If set, the reconcile of a vault instance"+
+		" taking longer is aborted and reported as failed, ex: 10m")
+	flag.DurationVar(&opts.requestTimeout, "request-timeout", 30*time.Second, "Maximum duration of a single"+
+		" request to a vault instance, unlimited when zero")
+	flag.DurationVar(&opts.shutdownGracePeriod, "shutdown-grace-period", 30*time.Second, "On SIGTERM or SIGINT,"+
+		" how long the configurations being applied may take to finish before in-flight requests are cancelled")
+	flag.StringVar(&deletionBudget, "deletion-budget", "", "Comma separated `rules` limiting the number, ex: vault_policies=10,"+
+		" or percentage, ex: vault_entities@https://vault.example.com=25%, of existing entries a top-level configuration"+
+		" may delete from an instance per run, * matching any configuration or instance")
+	flag.BoolVar(&allowMassDeletion, "allow-mass-deletion", false, "If true, deletions exceeding -deletion-budget"+
+		" are only logged as warnings")
+	flag.StringVar(&ownership, "ownership", "", "Comma separated `rules` of the entries owned by vault-manager per"+
+		" top-level configuration, ex: vault_policies=prefix:app-,vault_roles=glob:approle/*,vault_groups=metadata."+
+		" Entries that are not owned are neither updated nor deleted")
 	flag.Parse()
 
+	// `validate` only validates the desired state, flags may follow the subcommand
+	validateOnly := flag.Arg(0) == "validate"
+	if validateOnly {
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if opts.instanceConcurrency < 1 {
+		log.Fatalln("`instance-concurrency` must be at least 1")
+	}
+	vault.SetRequestTimeout(opts.requestTimeout)
+	budget, err := toplevel.ParseDeletionBudget(deletionBudget, allowMassDeletion)
+	if err != nil {
+		log.WithError(err).Fatal("invalid `deletion-budget`")
+	}
+	toplevel.SetDeletionBudget(budget)
+	ownerships, err := toplevel.ParseOwnership(ownership)
+	if err != nil {
+		log.WithError(err).Fatal("invalid `ownership`")
+	}
+	toplevel.SetOwnership(ownerships)
+
+	// no further configuration is applied once stopped by a termination signal
+	stopped, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	ctx, cancel := withGracePeriod(stopped, opts.shutdownGracePeriod)
+	defer cancel()
+
+	provider, err := newProvider(configSource)
+	if err != nil {
+		log.WithError(err).Fatal("invalid `config-source`")
+	}
+	referenceMode, err := toplevel.ParseReferenceMode(referenceChecks)
+	if err != nil {
+		log.WithError(err).Fatal("invalid `reference-checks`")
+	}
+	if validateOnly {
+		os.Exit(validate(provider, referenceMode))
+	}
+	// the last good bundle is kept by the provider
+	cached := state.NewCached(provider, bundleValidator(referenceMode))
+	// optionally persist the last good bundle so that it survives a restart
+	if cacheFile := os.Getenv("CONFIG_CACHE_FILE"); cacheFile != "" {
+		if err := cached.Persist(cacheFile); err != nil {
+			log.WithError(err).WithField("path", cacheFile).Warn("failed to restore persisted config")
+		}
+	}
+
+
+	// outcome of the runs served by /status
+	tracker := status.NewTracker()
 
 	var sleepDuration time.Duration
-	if !runOnce {
+	var metricsServer *http.Server
+	if !opts.runOnce {
 		// configure sleep duration
 		sleep, _ := os.LookupEnv("RECONCILE_SLEEP_TIME")
 		if sleep == "" {
@@ -106,133 +198,430 @@ func main() {
 			log.Println("`METRICS_SERVER_PORT` not set. Using default 9090")
 		}
 		http.Handle("/metrics", promhttp.Handler())
+		tracker.Register(http.DefaultServeMux)
 
+		metricsServer = &http.Server{Addr: fmt.Sprintf(":%s", port)}
 		go func() {
-			http.ListenAndServe(fmt.Sprintf(":%s", port), nil)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.WithError(err).Error("metrics server failed")
+			}
 		}()
 	}
 
 	for {
 		log.Info("Starting loop run.")
-		cfg, err := getConfig()
-		if err != nil {
-			log.WithError(err).Fatal("failed to parse config")
+
+		// used to exit with correct status from run-once execution
+		hasErrors := reconcile(ctx, stopped.Done(), cached, tracker, opts)
+
+		log.Info("Ending loop run.")
+
+		if opts.runOnce {
+			exitCode := 0
+			if hasErrors {
+				exitCode = 1
+			}
+			shutdown(metricsServer, exitCode)
+		}
+		// a termination signal interrupts the sleep right away
+		select {
+		case <-stopped.Done():
+			shutdown(metricsServer, 0)
+		case <-time.After(sleepDuration):
 		}
+	}
+}
 
-		// initialize vault clients and gather list of instance addresses for reconciliation
-		instanceAddresses := initInstances(cfg, kubeAuth, threadPoolSize)
+// withGracePeriod returns a context bounding the requests to vault instances. it is
+// cancelled once the grace period elapsed after stopped is done, so that the configurations
+// being applied when a termination signal is received are given a chance to finish
+func withGracePeriod(stopped context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	context.AfterFunc(stopped, func() {
+		log.WithField("grace_period", grace).Warn("received termination signal, finishing the configurations being applied")
+		time.AfterFunc(grace, cancel)
+	})
+	return ctx, cancel
+}
 
-		topLevelConfigs := []TopLevelConfig{}
+// how long the metrics server may take to finish serving requests on shutdown
+const metricsShutdownTimeout = 5 * time.Second
 
-		for key := range cfg {
-			c := TopLevelConfig{key, resolveConfigPriority(key)}
-			topLevelConfigs = append(topLevelConfigs, c)
+// shutdown stops the metrics server, flushes logs and exits with code
+func shutdown(metricsServer *http.Server, code int) {
+	log.Info("Terminating.")
+	if metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), metricsShutdownTimeout)
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			log.WithError(err).Warn("failed to stop metrics server")
 		}
+		cancel()
+	}
+	if logFile != nil {
+		logFile.Sync()
+		logFile.Close()
+	}
+	os.Exit(code)
+}
 
-		// sort configs by priority
-		sort.Sort(ByPriority(topLevelConfigs))
+// reconcile performs a single reconcile of every instance and returns whether errors occurred.
+// instances are reconciled concurrently. errors are logged and only affect the instance they
+// occurred for, so that a long-running loop keeps reconciling other instances and retries on the next run
+func reconcile(ctx context.Context, stopped <-chan struct{}, provider *state.Cached, tracker *status.Tracker,
+	opts options) bool {
+	hasErrors := false
 
-		// used to exit with correct status from run-once execution
-		hasErrors := false
-		// perform reconcile process per instance
-		for _, address := range instanceAddresses {
+	cfg, err := fetchConfig(ctx, provider)
+	tracker.RecordConfig(provider.Name(), err)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"provider": provider.Name(),
+			"kind":     vault.ErrorKind(err),
+		}).Error("failed to parse config")
+		// an invalid desired state aborts the run
+		if logProblems(err) {
+			return true
+		}
+		last, fetchedAt, ok := provider.Last()
+		if !ok {
+			return true
+		}
+		log.WithFields(log.Fields{
+			"provider": provider.Name(),
+			"age":      time.Since(fetchedAt).Round(time.Second),
+		}).Warn("reconciling last known good config")
+		cfg = last
+		hasErrors = true
+	}
+	if _, fetchedAt, ok := provider.Last(); ok && !opts.runOnce {
+		utils.RecordConfigAge(provider.Name(), time.Since(fetchedAt))
+	}
+
+	// initialize vault clients and gather list of instance addresses for reconciliation
+	instanceAddresses, err := initInstances(ctx, cfg, opts.kubeAuth, opts.threadPoolSize)
+	tracker.RecordClients(instanceAddresses, err)
+	if err != nil {
+		log.WithError(err).WithField("kind", vault.ErrorKind(err)).Error("failed to initialize instances")
+		return true
+	}
+
+	topLevelConfigs := []TopLevelConfig{}
+
+	for _, name := range toplevelNames(cfg) {
+		c := TopLevelConfig{name, resolveConfigPriority(name)}
+		topLevelConfigs = append(topLevelConfigs, c)
+	}
+
+	// sort configs by priority
+	sort.Sort(ByPriority(topLevelConfigs))
+
+	// collects every change computed during this run
+	plan := toplevel.NewPlan(opts.dryRun)
+
+	// perform reconcile process per instance
+	var mutex sync.Mutex
+	bwg := utils.NewBoundedWaitGroup(opts.instanceConcurrency)
+	for _, address := range instanceAddresses {
+		bwg.Add(1)
+		go func(address string) {
+			defer bwg.Done()
 			start := time.Now()
 			status := 0
 
-			for _, config := range topLevelConfigs {
-				// Marshal the contents of this object back into bytes so that it can be
-				// unmarshaled into a specific type in the application.
-				dataBytes, err := yaml.Marshal(cfg[config.Name])
-				if err != nil {
-					log.WithField("name", config.Name).Fatal("failed to remarshal configuration")
-				}
-				err = toplevel.Apply(config.Name, address, dataBytes, dryRun, threadPoolSize)
-				if err != nil {
-					log.Println(err)
-					log.Println(fmt.Sprintf("SKIPPING REMAINING RECONCILIATION FOR %s", address))
-					status = 1
-					hasErrors = true
-					break
+			failed, err := reconcileInstance(ctx, stopped, address, cfg, topLevelConfigs, plan, opts)
+			tracker.RecordRun(address, start, failed, err)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"instance": address,
+					"toplevel": failed,
+					"kind":     vault.ErrorKind(err),
+				}).Error("reconcile failed")
+				log.Println(fmt.Sprintf("SKIPPING REMAINING RECONCILIATION FOR %s", address))
+				if !opts.runOnce {
+					utils.RecordError(address, vault.ErrorKind(err))
+					var budgetErr *toplevel.DeletionBudgetError
+					if errors.As(err, &budgetErr) {
+						utils.RecordDeletionBudgetExceeded(address, budgetErr.Toplevel)
+					}
 				}
+				status = 1
+				mutex.Lock()
+				hasErrors = true
+				mutex.Unlock()
 			}
 
-			if !runOnce {
+			if !opts.runOnce {
 				utils.RecordMetrics(address, status, time.Since(start))
 			}
-			toplevel.ClearPolicies()
+		}(address)
+	}
+	bwg.Wait()
+
+	if opts.planOutput != "" {
+		if err := plan.WriteFile(opts.planOutput); err != nil {
+			log.WithError(err).WithField("path", opts.planOutput).Error("failed to write plan output")
+			hasErrors = true
 		}
+	}
+	return hasErrors
+}
 
-		log.Info("Ending loop run.")
+// reconcileInstance applies every top-level configuration to an instance in two phases:
+// creates and updates in order of priority, then deletions in reverse order of priority,
+// so that a configuration is never deleted while still referenced. the first error is
+// returned alongside the name of the failed configuration.
+// when the reconcile exceeds the instance timeout or ctx is cancelled, in-flight requests
+// are cancelled and the remaining configurations are not applied. once stopped is closed,
+// the configuration being applied finishes and the remaining configurations are not applied
+func reconcileInstance(ctx context.Context, stopped <-chan struct{}, address string, cfg state.Bundle, topLevelConfigs []TopLevelConfig,
+	plan *toplevel.Plan, opts options) (string, error) {
+	instanceCtx := ctx
+	if opts.instanceTimeout > 0 {
+		var cancel context.CancelFunc
+		instanceCtx, cancel = context.WithTimeout(ctx, opts.instanceTimeout)
+		defer cancel()
+	}
 
-		if runOnce {
-			if hasErrors {
-				os.Exit(1)
+	reversed := make([]TopLevelConfig, len(topLevelConfigs))
+	for i, config := range topLevelConfigs {
+		reversed[len(topLevelConfigs)-1-i] = config
+	}
+	phases := []struct {
+		phase   toplevel.Phase
+		configs []TopLevelConfig
+	}{
+		{toplevel.PhaseWrite, topLevelConfigs},
+		{toplevel.PhaseDelete, reversed},
+	}
+
+	// metrics are recorded once both phases of a configuration were applied, or on failure
+	durations := make(map[string]time.Duration)
+	var applied []string
+	record := func(failed string) {
+		if opts.runOnce {
+			return
+		}
+		for _, name := range applied {
+			recordToplevel(address, name, durations[name], plan, !opts.dryRun && name != failed)
+		}
+	}
+
+	for _, p := range phases {
+		for _, config := range p.configs {
+			select {
+			case <-stopped:
+				record(config.Name)
+				return config.Name, errStopped
+			default:
+			}
+			// Marshal the contents of this object back into bytes so that it can be
+			// unmarshaled into a specific type in the application.
+			dataBytes, err := marshalToplevel(cfg, config.Name)
+			if err == nil {
+				if _, ok := durations[config.Name]; !ok {
+					applied = append(applied, config.Name)
+				}
+				start := time.Now()
+				err = toplevel.Apply(instanceCtx, p.phase, config.Name, address, dataBytes, opts.dryRun, opts.threadPoolSize, plan)
+				durations[config.Name] += time.Since(start)
+			}
+			// some failures of cancelled requests are only logged by configurations
+			if err == nil {
+				err = instanceCtx.Err()
+			}
+			if err != nil {
+				if ctx.Err() == nil && instanceCtx.Err() != nil {
+					err = fmt.Errorf("reconcile exceeded the instance timeout of %s: %w", opts.instanceTimeout, err)
+				}
+				record(config.Name)
+				return config.Name, err
 			}
-			os.Exit(0)
-		} else {
-			time.Sleep(sleepDuration)
 		}
 	}
+	record("")
+	return "", nil
 }
 
-type config map[string]interface{}
-
-func getConfig() (config, error) {
-	graphqlServer := os.Getenv("GRAPHQL_SERVER")
-	if graphqlServer == "" {
-		graphqlServer = "http://localhost:4000/graphql"
+// recordToplevel records the metrics of applying a top-level configuration to an instance.
+// changes are counted as applied when applied is true
+func recordToplevel(address, name string, duration time.Duration, plan *toplevel.Plan, applied bool) {
+	changes := make(map[string]int)
+	for action, count := range plan.Counts(address, name) {
+		changes[string(action)] = count
 	}
+	utils.RecordToplevel(address, name, duration, changes, applied)
+}
 
-	graphqlQueryFile := os.Getenv("GRAPHQL_QUERY_FILE")
-	if graphqlQueryFile == "" {
-		graphqlQueryFile = "/query.graphql"
-	}
+// returned for the configurations of an instance that are not applied after a termination signal
+var errStopped = errors.New("reconcile stopped by termination signal")
 
-	graphqlUsername := os.Getenv("GRAPHQL_USERNAME")
-	graphqlPassword := os.Getenv("GRAPHQL_PASSWORD")
+// retries of a failed fetch of the desired state within a single run
+const (
+	configFetchAttempts = 3
+	configFetchSleep    = 5 * time.Second
+)
 
-	// create a graphql client
-	client := graphql.NewClient(graphqlServer)
+// fetchConfig fetches the desired state, retrying with backoff while the source
+// is unavailable. an invalid desired state is not retried
+func fetchConfig(ctx context.Context, provider state.Provider) (state.Bundle, error) {
+	var cfg state.Bundle
+	err := utils.Retry(configFetchAttempts, configFetchSleep, func() error {
+		b, err := provider.Fetch(ctx)
+		if err != nil {
+			if vault.ErrorKind(err) == vault.ConfigErrorKind {
+				return utils.RetryStop(err)
+			}
+			log.WithError(err).WithField("provider", provider.Name()).Warn("failed to fetch config")
+			return err
+		}
+		cfg = b
+		return nil
+	})
+	return cfg, err
+}
 
-	// read graphql query from file
-	query, err := os.ReadFile(graphqlQueryFile)
-	if err != nil {
-		log.WithField("path", graphqlQueryFile).Fatal("failed to read graphql query file")
+// bundleValidator returns a validator of the desired state run before any instance is
+// accessed. dangling references are logged as warnings when mode is toplevel.ReferencesWarn
+func bundleValidator(mode toplevel.ReferenceMode) state.Validator {
+	return func(cfg state.Bundle) error {
+		if err := state.RequireKeys(instancesKey)(cfg); err != nil {
+			return err
+		}
+		cfgs := make(map[string][]byte)
+		for _, name := range toplevelNames(cfg) {
+			dataBytes, err := marshalToplevel(cfg, name)
+			if err != nil {
+				return err
+			}
+			cfgs[name] = dataBytes
+		}
+		warnings, err := toplevel.Validate(cfgs, mode)
+		for _, p := range warnings {
+			log.WithFields(log.Fields{
+				"toplevel": p.Toplevel,
+				"instance": p.Instance,
+				"entry":    p.Entry,
+			}).Warn(p.Message)
+		}
+		return err
 	}
+}
 
-	// make a request
-	req := graphql.NewRequest(string(query))
+// logProblems logs every problem of a validation error and returns whether err is one
+func logProblems(err error) bool {
+	var validationErr *toplevel.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	for _, p := range validationErr.Problems {
+		log.WithFields(log.Fields{
+			"toplevel": p.Toplevel,
+			"instance": p.Instance,
+			"entry":    p.Entry,
+		}).Error(p.Message)
+	}
+	return true
+}
+
+// validate fetches and validates the desired state without accessing any instance.
+// returns the exit code of the `validate` subcommand
+func validate(provider state.Provider, mode toplevel.ReferenceMode) int {
+	cfg, err := provider.Fetch(context.Background())
+	if err != nil {
+		log.WithError(err).WithField("provider", provider.Name()).Error("failed to parse config")
+		return 1
+	}
+	if err := bundleValidator(mode)(cfg); err != nil {
+		if !logProblems(err) {
+			log.WithError(err).Error("invalid desired state")
+		}
+		return 1
+	}
+	log.WithField("provider", provider.Name()).Info("desired state is valid")
+	return 0
+}
 
-	// set basic auth header
-	if graphqlUsername != "" && graphqlPassword != "" {
-		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(graphqlUsername+":"+graphqlPassword)))
+// top-level key of the instances to reconcile. not included in the standard top-level reconcile loop
+const instancesKey = "vault_instances"
+
+// toplevelNames returns the sorted names of the top-level configurations of the desired
+// state. keys included by another configuration are applied by that configuration
+func toplevelNames(cfg state.Bundle) []string {
+	names := []string{}
+	seen := make(map[string]bool)
+	for _, key := range cfg.Keys() {
+		if key == instancesKey {
+			continue
+		}
+		name := toplevel.IncludedBy(key)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
+	return names
+}
 
-	// define a Context for the request
-	ctx := context.Background()
+// marshalToplevel returns the desired state of a top-level configuration, the entries of
+// its key or, when it includes other keys, a mapping of its keys to their entries
+func marshalToplevel(cfg state.Bundle, name string) ([]byte, error) {
+	keys := toplevel.Keys(name)
+	if len(keys) == 1 {
+		return cfg.Marshal(name)
+	}
+	return cfg.MarshalKeys(keys...)
+}
 
-	var response map[string]interface{}
+// prefixes of `-config-source` values selecting a provider
+const (
+	fileSourcePrefix  = "file:"
+	httpSourcePrefix  = "http://"
+	httpsSourcePrefix = "https://"
+)
 
-	// execute query and capture the response
-	if err := client.Run(ctx, req, &response); err != nil {
-		return config{}, errors.Wrap(err, "failed to query graphql server")
+// newProvider returns the desired state provider for a `-config-source` value.
+// provider specific configuration is read from the environment
+func newProvider(source string) (state.Provider, error) {
+	switch {
+	case source == "graphql":
+		return state.NewGraphQLProvider(state.GraphQLConfig{
+			Server:    defaultGetenv("GRAPHQL_SERVER", "http://localhost:4000/graphql"),
+			QueryFile: defaultGetenv("GRAPHQL_QUERY_FILE", "/query.graphql"),
+			Username:  os.Getenv("GRAPHQL_USERNAME"),
+			Password:  os.Getenv("GRAPHQL_PASSWORD"),
+		}), nil
+	case strings.HasPrefix(source, fileSourcePrefix):
+		return state.NewFileProvider(strings.TrimPrefix(source, fileSourcePrefix)), nil
+	case strings.HasPrefix(source, httpSourcePrefix), strings.HasPrefix(source, httpsSourcePrefix):
+		headers := map[string]string{}
+		if authorization := os.Getenv("CONFIG_SOURCE_AUTHORIZATION"); authorization != "" {
+			headers["Authorization"] = authorization
+		}
+		return state.NewHTTPProvider(state.HTTPConfig{URL: source, Headers: headers}), nil
+	case source == "stdin":
+		return state.NewReaderProvider(os.Stdin), nil
+	default:
+		return nil, fmt.Errorf("unsupported source %q, must be `graphql`, `file:<dir>`, an `http(s)://` url or `stdin`", source)
 	}
+}
 
-	return response, nil
+func defaultGetenv(name, defaultValue string) string {
+	if env := os.Getenv(name); env != "" {
+		return env
+	}
+	return defaultValue
 }
 
 // gathers instances referenced across all applicable file definitions and initializes the clients
 // clients are set as private global witihn client.go
 // return is list of strings containing addresses of vault instances
-func initInstances(cfg config, kubeAuth bool, threadPoolSize int) []string {
-	const INSTANCE_KEY = "vault_instances"
-	dataBytes, err := yaml.Marshal(cfg[INSTANCE_KEY])
+func initInstances(ctx context.Context, cfg state.Bundle, kubeAuth bool, threadPoolSize int) ([]string, error) {
+	dataBytes, err := cfg.Marshal(instancesKey)
 	if err != nil {
-		log.WithField("name", INSTANCE_KEY).Fatal("failed to remarshal instance configuration")
+		return nil, err
 	}
-	// do not include `vault_instances` in standard top-level reconcile loop
-	delete(cfg, INSTANCE_KEY)
-	return vault.GetInstances(dataBytes, kubeAuth, threadPoolSize)
+	return vault.GetInstances(ctx, dataBytes, kubeAuth, threadPoolSize)
 }
 
 func resolveConfigPriority(s string) int {
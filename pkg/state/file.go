@@ -0,0 +1,111 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"gopkg.in/yaml.v2"
+)
+
+// FileProvider reads the desired state from the YAML and JSON files within a directory
+// and its sub-directories. A file either contains a mapping of top-level keys, ex:
+// `vault_policies`, to their entries or a list of entries for the top-level key
+// matching the file name, ex: `vault_policies.yaml`. Entries of the same key
+// across files are concatenated in lexical order of the file paths.
+type FileProvider struct {
+	dir string
+}
+
+var _ Provider = FileProvider{}
+
+func NewFileProvider(dir string) FileProvider {
+	return FileProvider{dir: dir}
+}
+
+func (p FileProvider) Name() string {
+	return "file"
+}
+
+func (p FileProvider) Fetch(ctx context.Context) (Bundle, error) {
+	dir := p.dir
+	paths := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			if !info.IsDir() {
+				paths = append(paths, path)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, vault.NewConfigError(fmt.Sprintf("failed to list desired state files within `%s`", dir), err)
+	}
+	sort.Strings(paths)
+
+	bundle := Bundle{}
+	for _, path := range paths {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, vault.NewConfigError(fmt.Sprintf("failed to read desired state file `%s`", path), err)
+		}
+		if err := bundle.merge(raw, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))); err != nil {
+			return nil, vault.NewConfigError(fmt.Sprintf("invalid desired state file `%s`", path), err)
+		}
+	}
+	return bundle, nil
+}
+
+// merges yaml or json content into the bundle. content is either a mapping of
+// top-level keys to their entries or a list of entries for the top-level key
+// named after the source of the content
+func (b Bundle) merge(raw []byte, name string) error {
+	var content interface{}
+	if err := yaml.Unmarshal(raw, &content); err != nil {
+		return err
+	}
+
+	switch c := content.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		if name == "" {
+			return fmt.Errorf("content is a list but its top-level key is unknown")
+		}
+		return b.appendEntries(name, c)
+	case map[interface{}]interface{}:
+		for k, v := range c {
+			entries, ok := v.([]interface{})
+			if !ok && v != nil {
+				return fmt.Errorf("value of `%v` is not a list", k)
+			}
+			if err := b.appendEntries(fmt.Sprintf("%v", k), entries); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("content is neither a mapping of top-level keys nor a list")
+	}
+}
+
+// appends entries to the list of a top-level key
+func (b Bundle) appendEntries(key string, entries []interface{}) error {
+	if _, exists := b[key]; !exists {
+		b[key] = []interface{}{}
+	}
+	existing, ok := b[key].([]interface{})
+	if !ok {
+		return fmt.Errorf("value of `%s` is not a list", key)
+	}
+	b[key] = append(existing, entries...)
+	return nil
+}
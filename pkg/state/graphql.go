@@ -0,0 +1,64 @@
+package state
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/machinebox/graphql"
+	"github.com/pkg/errors"
+)
+
+// GraphQLConfig configures the retrieval of the desired state from a qontract graphql server
+type GraphQLConfig struct {
+	Server    string
+	QueryFile string
+	Username  string
+	Password  string
+}
+
+// GraphQLProvider fetches the desired state by running the query within
+// QueryFile against a qontract graphql server.
+type GraphQLProvider struct {
+	config GraphQLConfig
+}
+
+var _ Provider = GraphQLProvider{}
+
+func NewGraphQLProvider(config GraphQLConfig) GraphQLProvider {
+	return GraphQLProvider{config: config}
+}
+
+func (p GraphQLProvider) Name() string {
+	return "graphql"
+}
+
+func (p GraphQLProvider) Fetch(ctx context.Context) (Bundle, error) {
+	// create a graphql client
+	client := graphql.NewClient(p.config.Server)
+
+	// read graphql query from file
+	query, err := os.ReadFile(p.config.QueryFile)
+	if err != nil {
+		return nil, vault.NewConfigError(fmt.Sprintf("failed to read graphql query file `%s`", p.config.QueryFile), err)
+	}
+
+	// make a request
+	req := graphql.NewRequest(string(query))
+
+	// set basic auth header
+	if p.config.Username != "" && p.config.Password != "" {
+		req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(p.config.Username+":"+p.config.Password)))
+	}
+
+	var response map[string]interface{}
+
+	// execute query and capture the response
+	if err := client.Run(ctx, req, &response); err != nil {
+		return nil, errors.Wrap(err, "failed to query graphql server")
+	}
+
+	return response, nil
+}
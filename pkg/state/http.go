@@ -0,0 +1,77 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// default timeout of requests to an HTTP endpoint
+const defaultHTTPTimeout = 30 * time.Second
+
+// HTTPConfig configures the retrieval of the desired state from an HTTP endpoint
+type HTTPConfig struct {
+	URL string
+	// optional headers sent with each request, ex: `Authorization`
+	Headers map[string]string
+	// defaults to 30s when unset
+	Timeout time.Duration
+}
+
+// HTTPProvider fetches the desired state from an HTTP endpoint returning a JSON
+// (or YAML) mapping of top-level keys to their entries.
+type HTTPProvider struct {
+	config HTTPConfig
+	client *http.Client
+}
+
+var _ Provider = HTTPProvider{}
+
+func NewHTTPProvider(config HTTPConfig) HTTPProvider {
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = defaultHTTPTimeout
+	}
+	return HTTPProvider{
+		config: config,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (p HTTPProvider) Name() string {
+	return "http"
+}
+
+func (p HTTPProvider) Fetch(ctx context.Context) (Bundle, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.URL, nil)
+	if err != nil {
+		return nil, vault.NewConfigError(fmt.Sprintf("invalid desired state url `%s`", p.config.URL), err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range p.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request desired state from `%s`: %w", p.config.URL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to request desired state from `%s`: unexpected status %s", p.config.URL, resp.Status)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read desired state from `%s`: %w", p.config.URL, err)
+	}
+	bundle := Bundle{}
+	if err := bundle.merge(raw, ""); err != nil {
+		return nil, vault.NewConfigError(fmt.Sprintf("invalid desired state returned by `%s`", p.config.URL), err)
+	}
+	return bundle, nil
+}
@@ -0,0 +1,196 @@
+// Package state implements the providers of the desired state reconciled by
+// vault-manager, ex: a qontract graphql server, local files or an HTTP endpoint.
+package state
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Bundle is the desired state, a mapping of top-level keys, ex: `vault_policies`,
+// to the list of their entries.
+type Bundle map[string]interface{}
+
+// Marshal returns the entries of a top-level key as yaml so that they can be
+// unmarshalled into the type of a specific top-level configuration.
+func (b Bundle) Marshal(key string) ([]byte, error) {
+	data, err := yaml.Marshal(b[key])
+	if err != nil {
+		return nil, vault.NewConfigError(fmt.Sprintf("failed to remarshal configuration `%s`", key), err)
+	}
+	return data, nil
+}
+
+// MarshalKeys returns a mapping of several top-level keys to their entries as yaml, ex:
+// for a top-level configuration including the entries of other keys. Missing keys
+// are left out.
+func (b Bundle) MarshalKeys(keys ...string) ([]byte, error) {
+	subset := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		if entries, exists := b[key]; exists {
+			subset[key] = entries
+		}
+	}
+	data, err := yaml.Marshal(subset)
+	if err != nil {
+		return nil, vault.NewConfigError(fmt.Sprintf("failed to remarshal configurations `%s`", strings.Join(keys, "`, `")), err)
+	}
+	return data, nil
+}
+
+// Keys returns the sorted top-level keys of the bundle
+func (b Bundle) Keys() []string {
+	keys := make([]string, 0, len(b))
+	for k := range b {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Provider fetches the desired state from a source.
+type Provider interface {
+	// Name identifies the provider within logs and metrics
+	Name() string
+	Fetch(ctx context.Context) (Bundle, error)
+}
+
+// Validator is a hook that rejects an invalid bundle before it is reconciled.
+type Validator func(Bundle) error
+
+// RequireKeys returns a Validator that rejects bundles missing any of keys
+func RequireKeys(keys ...string) Validator {
+	return func(b Bundle) error {
+		for _, k := range keys {
+			if _, exists := b[k]; !exists {
+				return fmt.Errorf("required top-level key `%s` is missing", k)
+			}
+		}
+		return nil
+	}
+}
+
+// Cached wraps a provider, validates every fetched bundle and keeps the last
+// bundle that was fetched and validated successfully. The last good bundle is
+// optionally persisted to disk so that it survives a restart.
+type Cached struct {
+	provider  Provider
+	validate  Validator
+	mutex     sync.Mutex
+	last      Bundle
+	fetchedAt time.Time
+	// optional file the last good bundle is persisted to
+	path string
+}
+
+var _ Provider = &Cached{}
+
+// NewCached returns a caching provider. validate may be nil.
+func NewCached(provider Provider, validate Validator) *Cached {
+	return &Cached{
+		provider: provider,
+		validate: validate,
+	}
+}
+
+func (c *Cached) Name() string {
+	return c.provider.Name()
+}
+
+// Fetch fetches and validates a bundle from the wrapped provider. The last good
+// bundle is only replaced when both succeed.
+func (c *Cached) Fetch(ctx context.Context) (Bundle, error) {
+	b, err := c.provider.Fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.validate != nil {
+		if err := c.validate(b); err != nil {
+			return nil, vault.NewConfigError(fmt.Sprintf("[%s] invalid desired state", c.Name()), err)
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.last = b
+	c.fetchedAt = time.Now()
+	if c.path != "" {
+		// the bundle is still good when it cannot be persisted
+		if err := writeBundle(c.path, b); err != nil {
+			log.WithError(err).WithField("path", c.path).Warnf("[%s] failed to persist desired state", c.Name())
+		}
+	}
+	return b, nil
+}
+
+// Persist persists every good bundle to path and restores the bundle previously
+// persisted there, if any, as the last good bundle. Its fetch time is the time the
+// file was last written.
+func (c *Cached) Persist(path string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.path = path
+
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return vault.NewConfigError(fmt.Sprintf("[%s] failed to read persisted desired state `%s`", c.Name(), path), err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return vault.NewConfigError(fmt.Sprintf("[%s] failed to read persisted desired state `%s`", c.Name(), path), err)
+	}
+	b := Bundle{}
+	if err := b.merge(raw, ""); err != nil {
+		return vault.NewConfigError(fmt.Sprintf("[%s] invalid persisted desired state `%s`", c.Name(), path), err)
+	}
+	if c.validate != nil {
+		if err := c.validate(b); err != nil {
+			return vault.NewConfigError(fmt.Sprintf("[%s] invalid persisted desired state `%s`", c.Name(), path), err)
+		}
+	}
+	c.last = b
+	c.fetchedAt = info.ModTime()
+	return nil
+}
+
+// Last returns the last good bundle and when it was fetched. The returned bool
+// is false when no bundle was fetched successfully yet.
+func (c *Cached) Last() (Bundle, time.Time, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.last, c.fetchedAt, c.last != nil
+}
+
+// writes the bundle as yaml to path. the file is replaced atomically so that a
+// partially written bundle is never restored
+func writeBundle(path string, b Bundle) error {
+	data, err := yaml.Marshal(b)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
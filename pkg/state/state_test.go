@@ -0,0 +1,173 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProvider(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "policies"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bundle.yaml"),
+		[]byte("vault_instances:\n- address: https://vault\nvault_policies:\n- name: a\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "policies", "vault_policies.json"),
+		[]byte(`[{"name": "b"}]`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644))
+
+	bundle, err := NewFileProvider(dir).Fetch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"vault_instances", "vault_policies"}, bundle.Keys())
+	require.Len(t, bundle["vault_policies"], 2)
+
+	data, err := bundle.Marshal("vault_policies")
+	require.NoError(t, err)
+	require.Equal(t, "- name: a\n- name: b\n", string(data))
+}
+
+func TestMarshalKeys(t *testing.T) {
+	t.Parallel()
+
+	bundle := Bundle{
+		"vault_groups":          []interface{}{map[interface{}]interface{}{"org_username": "a"}},
+		"vault_declared_groups": []interface{}{map[interface{}]interface{}{"name": "b"}},
+	}
+	data, err := bundle.MarshalKeys("vault_groups", "vault_declared_groups")
+	require.NoError(t, err)
+	require.Equal(t, "vault_declared_groups:\n- name: b\nvault_groups:\n- org_username: a\n", string(data))
+
+	data, err = bundle.MarshalKeys("vault_groups", "vault_missing")
+	require.NoError(t, err)
+	require.Equal(t, "vault_groups:\n- org_username: a\n", string(data))
+}
+
+func TestFileProviderInvalidContent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bundle.yaml"), []byte("vault_policies: a\n"), 0644))
+
+	_, err := NewFileProvider(dir).Fetch(context.Background())
+	require.Error(t, err)
+	require.Equal(t, vault.ConfigErrorKind, vault.ErrorKind(err))
+}
+
+func TestHTTPProvider(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"vault_policies": [{"name": "a"}]}`))
+	}))
+	defer server.Close()
+
+	bundle, err := NewHTTPProvider(HTTPConfig{
+		URL:     server.URL,
+		Headers: map[string]string{"Authorization": "Bearer token"},
+	}).Fetch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"vault_policies"}, bundle.Keys())
+
+	_, err = NewHTTPProvider(HTTPConfig{URL: server.URL}).Fetch(context.Background())
+	require.Error(t, err)
+}
+
+func TestReaderProvider(t *testing.T) {
+	t.Parallel()
+
+	provider := NewReaderProvider(strings.NewReader("vault_policies:\n- name: a\n"))
+	for i := 0; i < 2; i++ {
+		bundle, err := provider.Fetch(context.Background())
+		require.NoError(t, err)
+		require.Len(t, bundle["vault_policies"], 1)
+	}
+}
+
+type fakeProvider struct {
+	bundles []Bundle
+	errs    []error
+	calls   int
+}
+
+func (p *fakeProvider) Name() string {
+	return "fake"
+}
+
+func (p *fakeProvider) Fetch(ctx context.Context) (Bundle, error) {
+	i := p.calls
+	p.calls++
+	return p.bundles[i], p.errs[i]
+}
+
+func TestCached(t *testing.T) {
+	t.Parallel()
+
+	good := Bundle{"vault_instances": []interface{}{}}
+	provider := &fakeProvider{
+		bundles: []Bundle{good, nil, {"vault_policies": []interface{}{}}},
+		errs:    []error{nil, errors.New("unavailable"), nil},
+	}
+	cached := NewCached(provider, RequireKeys("vault_instances"))
+
+	_, _, ok := cached.Last()
+	require.False(t, ok)
+
+	bundle, err := cached.Fetch(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, good, bundle)
+
+	// failed fetch keeps the last good bundle
+	_, err = cached.Fetch(context.Background())
+	require.EqualError(t, err, "unavailable")
+
+	// invalid bundle is rejected and keeps the last good bundle
+	_, err = cached.Fetch(context.Background())
+	require.Equal(t, vault.ConfigErrorKind, vault.ErrorKind(err))
+
+	last, fetchedAt, ok := cached.Last()
+	require.True(t, ok)
+	require.Equal(t, good, last)
+	require.False(t, fetchedAt.IsZero())
+}
+
+func TestCachedPersist(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "bundle.yaml")
+	good := Bundle{"vault_instances": []interface{}{map[interface{}]interface{}{"address": "https://vault"}}}
+
+	cached := NewCached(&fakeProvider{bundles: []Bundle{good}, errs: []error{nil}}, RequireKeys("vault_instances"))
+	require.NoError(t, cached.Persist(path))
+	_, _, ok := cached.Last()
+	require.False(t, ok)
+	_, err := cached.Fetch(context.Background())
+	require.NoError(t, err)
+
+	// a new instance restores the persisted bundle
+	restored := NewCached(&fakeProvider{}, RequireKeys("vault_instances"))
+	require.NoError(t, restored.Persist(path))
+	last, fetchedAt, ok := restored.Last()
+	require.True(t, ok)
+	require.Equal(t, good, last)
+	require.False(t, fetchedAt.IsZero())
+
+	// an invalid persisted bundle is not restored
+	require.NoError(t, os.WriteFile(path, []byte("vault_policies: []\n"), 0600))
+	invalid := NewCached(&fakeProvider{}, RequireKeys("vault_instances"))
+	require.Error(t, invalid.Persist(path))
+	_, _, ok = invalid.Last()
+	require.False(t, ok)
+}
@@ -0,0 +1,46 @@
+package state
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// ReaderProvider reads the desired state, a YAML or JSON mapping of top-level keys
+// to their entries, from a reader such as stdin. As a reader can only be consumed
+// once, the bundle read by the first fetch is returned by every following fetch.
+type ReaderProvider struct {
+	reader io.Reader
+	once   sync.Once
+	bundle Bundle
+	err    error
+}
+
+var _ Provider = &ReaderProvider{}
+
+func NewReaderProvider(reader io.Reader) *ReaderProvider {
+	return &ReaderProvider{reader: reader}
+}
+
+func (p *ReaderProvider) Name() string {
+	return "stdin"
+}
+
+func (p *ReaderProvider) Fetch(ctx context.Context) (Bundle, error) {
+	p.once.Do(func() {
+		raw, err := io.ReadAll(p.reader)
+		if err != nil {
+			p.err = vault.NewConfigError("failed to read desired state", err)
+			return
+		}
+		bundle := Bundle{}
+		if err := bundle.merge(raw, ""); err != nil {
+			p.err = vault.NewConfigError("invalid desired state", err)
+			return
+		}
+		p.bundle = bundle
+	})
+	return p.bundle, p.err
+}
@@ -0,0 +1,169 @@
+// Package status tracks the outcome of reconcile runs and serves it over HTTP
+// for Kubernetes probes and dashboards.
+package status
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// outcomes of the reconcile of an instance
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Instance is the outcome of the last reconcile of a vault instance
+type Instance struct {
+	Address  string    `json:"address"`
+	LastRun  time.Time `json:"last_run"`
+	Outcome  string    `json:"outcome"`
+	Duration float64   `json:"duration_seconds"`
+	// the top-level configuration that failed, ex: `vault_policies`
+	FailedToplevel string `json:"failed_toplevel,omitempty"`
+	Error          string `json:"error,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+}
+
+// Config is the outcome of the last fetch of the desired state
+type Config struct {
+	Provider  string    `json:"provider"`
+	LastFetch time.Time `json:"last_fetch"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Status is the document served by /status
+type Status struct {
+	Ready     bool       `json:"ready"`
+	Reasons   []string   `json:"reasons,omitempty"`
+	Config    *Config    `json:"config,omitempty"`
+	Instances []Instance `json:"instances"`
+}
+
+// Tracker records the outcome of reconcile runs. It is safe to be used by instances
+// reconciled concurrently while being served.
+type Tracker struct {
+	mutex sync.RWMutex
+	// nil until the desired state was fetched once
+	config *Config
+	// whether the clients of the last run were initialized
+	clientsReady bool
+	clientsError string
+	instances    map[string]Instance
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{instances: make(map[string]Instance)}
+}
+
+// RecordConfig records the outcome of a fetch of the desired state
+func (t *Tracker) RecordConfig(provider string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.config = &Config{Provider: provider, LastFetch: time.Now()}
+	if err != nil {
+		t.config.Error = err.Error()
+	}
+}
+
+// RecordClients records the outcome of the initialization of the instance clients.
+// Instances that are no longer reconciled are forgotten.
+func (t *Tracker) RecordClients(addresses []string, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.clientsReady = err == nil
+	t.clientsError = ""
+	if err != nil {
+		t.clientsError = err.Error()
+		return
+	}
+	reconciled := make(map[string]bool, len(addresses))
+	for _, address := range addresses {
+		reconciled[address] = true
+	}
+	for address := range t.instances {
+		if !reconciled[address] {
+			delete(t.instances, address)
+		}
+	}
+}
+
+// RecordRun records the outcome of the reconcile of an instance started at start.
+// failed is the top-level configuration that returned err.
+func (t *Tracker) RecordRun(address string, start time.Time, failed string, err error) {
+	instance := Instance{
+		Address:  address,
+		LastRun:  start,
+		Outcome:  OutcomeSuccess,
+		Duration: time.Since(start).Seconds(),
+	}
+	if err != nil {
+		instance.Outcome = OutcomeFailure
+		instance.FailedToplevel = failed
+		instance.Error = err.Error()
+		instance.Kind = vault.ErrorKind(err)
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.instances[address] = instance
+}
+
+// Status returns the recorded outcomes, instances are sorted by address
+func (t *Tracker) Status() Status {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	status := Status{Instances: make([]Instance, 0, len(t.instances))}
+	switch {
+	case t.config == nil:
+		status.Reasons = append(status.Reasons, "desired state was not fetched yet")
+	case t.config.Error != "":
+		status.Reasons = append(status.Reasons, fmt.Sprintf("last fetch of the desired state failed: %s", t.config.Error))
+	}
+	if t.config != nil {
+		config := *t.config
+		status.Config = &config
+	}
+	if !t.clientsReady {
+		reason := "instance clients are not initialized"
+		if t.clientsError != "" {
+			reason = fmt.Sprintf("%s: %s", reason, t.clientsError)
+		}
+		status.Reasons = append(status.Reasons, reason)
+	}
+	status.Ready = len(status.Reasons) == 0
+	for _, instance := range t.instances {
+		status.Instances = append(status.Instances, instance)
+	}
+	sort.Slice(status.Instances, func(i, j int) bool {
+		return status.Instances[i].Address < status.Instances[j].Address
+	})
+	return status
+}
+
+// Register adds the /healthz, /readyz and /status handlers to mux
+func (t *Tracker) Register(mux *http.ServeMux) {
+	// the process is alive as long as it serves requests
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		status := t.Status()
+		if !status.Ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(strings.Join(status.Reasons, "\n") + "\n"))
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(t.Status())
+	})
+}
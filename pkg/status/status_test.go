@@ -0,0 +1,76 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func serve(t *testing.T, tracker *Tracker, path string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	tracker.Register(mux)
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+	return recorder
+}
+
+func TestReadiness(t *testing.T) {
+	t.Parallel()
+
+	tracker := NewTracker()
+	require.Equal(t, http.StatusOK, serve(t, tracker, "/healthz").Code)
+
+	recorder := serve(t, tracker, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	require.Equal(t, "desired state was not fetched yet\ninstance clients are not initialized\n",
+		recorder.Body.String())
+
+	tracker.RecordConfig("file", nil)
+	tracker.RecordClients([]string{"https://a"}, nil)
+	require.Equal(t, http.StatusOK, serve(t, tracker, "/readyz").Code)
+
+	// failed fetch of the desired state
+	tracker.RecordConfig("file", errors.New("unavailable"))
+	recorder = serve(t, tracker, "/readyz")
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	require.Equal(t, "last fetch of the desired state failed: unavailable\n", recorder.Body.String())
+}
+
+func TestStatus(t *testing.T) {
+	t.Parallel()
+
+	tracker := NewTracker()
+	tracker.RecordConfig("graphql", nil)
+	tracker.RecordClients([]string{"https://a", "https://b", "https://c"}, nil)
+	start := time.Now()
+	tracker.RecordRun("https://b", start, "vault_policies",
+		&vault.APIError{Instance: "https://b", Op: "failed to list existing policies", Err: errors.New("503")})
+	tracker.RecordRun("https://a", start, "", nil)
+	tracker.RecordRun("https://c", start, "", nil)
+	// instances that are no longer reconciled are forgotten
+	tracker.RecordClients([]string{"https://a", "https://b"}, nil)
+
+	recorder := serve(t, tracker, "/status")
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var status Status
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &status))
+	require.True(t, status.Ready)
+	require.Equal(t, "graphql", status.Config.Provider)
+	require.Len(t, status.Instances, 2)
+	require.Equal(t, "https://a", status.Instances[0].Address)
+	require.Equal(t, OutcomeSuccess, status.Instances[0].Outcome)
+	require.Empty(t, status.Instances[0].FailedToplevel)
+	require.Equal(t, "https://b", status.Instances[1].Address)
+	require.Equal(t, OutcomeFailure, status.Instances[1].Outcome)
+	require.Equal(t, "vault_policies", status.Instances[1].FailedToplevel)
+	require.Equal(t, vault.APIErrorKind, status.Instances[1].Kind)
+	require.True(t, status.Instances[1].LastRun.Equal(start))
+}
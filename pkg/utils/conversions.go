@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+
+	"gopkg.in/yaml.v2"
 )
 
 // Yaml unmarshal limitation causes nested options objects to be decode as strings with json format
@@ -24,3 +26,25 @@ func UnmarshalJsonObj(key string, obj interface{}) (map[string]interface{}, erro
 	}
 	return unmarshalled, nil
 }
+
+// JSONMap is a mapping decoded either from a yaml mapping, ex: read from a file, or from a
+// string holding a json object, ex: a field of type `json` returned by the graphql server
+type JSONMap[V any] map[string]V
+
+func (m *JSONMap[V]) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var decoded map[string]V
+	var s string
+	if err := unmarshal(&s); err != nil {
+		if err := unmarshal(&decoded); err != nil {
+			return err
+		}
+		*m = decoded
+		return nil
+	}
+	// json objects are valid yaml, keeping nested values decoded as by yaml
+	if err := yaml.Unmarshal([]byte(s), &decoded); err != nil {
+		return fmt.Errorf("failed to decode json object: %w", err)
+	}
+	*m = decoded
+	return nil
+}
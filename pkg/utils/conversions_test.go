@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestJSONMap(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		expected    JSONMap[interface{}]
+		expectedErr string
+	}{
+		{
+			"yaml mapping",
+			"metadata:\n  team: sre\n  nested:\n    a: 1\n",
+			JSONMap[interface{}]{"team": "sre", "nested": map[interface{}]interface{}{"a": 1}},
+			"",
+		},
+		{
+			"json object",
+			`metadata: '{"team": "sre", "nested": {"a": 1}}'`,
+			JSONMap[interface{}]{"team": "sre", "nested": map[interface{}]interface{}{"a": 1}},
+			"",
+		},
+		{
+			"missing",
+			"other: value\n",
+			nil,
+			"",
+		},
+		{
+			"string that is not a json object",
+			"metadata: sre\n",
+			nil,
+			"failed to decode json object: yaml: unmarshal errors:\n  line 1: cannot unmarshal !!str `sre` into map[string]interface {}",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			var decoded struct {
+				Metadata JSONMap[interface{}] `yaml:"metadata"`
+			}
+			err := yaml.Unmarshal([]byte(c.given), &decoded)
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expected, decoded.Metadata)
+		})
+	}
+}
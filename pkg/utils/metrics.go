@@ -28,6 +28,39 @@ var (
 			"integration",
 		},
 	)
+	reconcileErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_manager_reconcile_errors_total",
+			Help: "Increment by one for each failed reconcile of a specific vault instance, by kind of error.",
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"kind",
+		},
+	)
+	deletionBudgetCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_manager_deletion_budget_exceeded_total",
+			Help: "Increment by one for each reconcile of a specific vault instance aborted as a top-level configuration exceeded its deletion budget.",
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"toplevel",
+		},
+	)
+	configAgeGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "vault_manager_config_age_seconds",
+			Help: `Age in seconds of the desired state reconciled by the last run. ` +
+				`Grows while the desired state cannot be fetched and the last known good one is reconciled.`,
+		},
+		[]string{
+			"integration",
+			"provider",
+		},
+	)
 	executionDurationGauge = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "qontract_reconcile_last_run_seconds",
@@ -38,6 +71,74 @@ var (
 			"integration",
 		},
 	)
+	changesCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_manager_changes_total",
+			Help: `Increment by the number of writes, updates and deletes planned for a top-level configuration of a ` +
+				`specific vault instance, and by the number applied once the configuration was applied successfully.`,
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"toplevel",
+			"action",
+			"phase",
+		},
+	)
+	driftGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "vault_manager_drift_items",
+			Help: "Number of items of a top-level configuration out of sync with the desired state at the start of the last run.",
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"toplevel",
+		},
+	)
+	toplevelDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vault_manager_toplevel_duration_seconds",
+			Help:    "Duration in seconds of applying a top-level configuration to a specific vault instance.",
+			Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"toplevel",
+		},
+	)
+	vaultRequestCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "vault_manager_vault_requests_total",
+			Help: `Increment by one for each request sent to a specific vault instance, by http method and status code. ` +
+				`Requests that did not get a response are counted with code "error".`,
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"method",
+			"code",
+		},
+	)
+	vaultRequestDurationHistogram = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "vault_manager_vault_request_duration_seconds",
+			Help:    "Duration in seconds of requests sent to a specific vault instance, by http method.",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{
+			"shard_id",
+			"integration",
+			"method",
+		},
+	)
+)
+
+// phases of the changes counted by vault_manager_changes_total
+const (
+	PhasePlanned = "planned"
+	PhaseApplied = "applied"
 )
 
 // register custom metrics at package import
@@ -45,6 +146,14 @@ func init() {
 	prometheus.MustRegister(reconcileSuccessCounter)
 	prometheus.MustRegister(lastReconcileSuccessGauge)
 	prometheus.MustRegister(executionDurationGauge)
+	prometheus.MustRegister(reconcileErrorCounter)
+	prometheus.MustRegister(deletionBudgetCounter)
+	prometheus.MustRegister(configAgeGauge)
+	prometheus.MustRegister(changesCounter)
+	prometheus.MustRegister(driftGauge)
+	prometheus.MustRegister(toplevelDurationHistogram)
+	prometheus.MustRegister(vaultRequestCounter)
+	prometheus.MustRegister(vaultRequestDurationHistogram)
 }
 
 func RecordMetrics(instance string, status int, duration time.Duration) {
@@ -71,3 +180,105 @@ func RecordMetrics(instance string, status int, duration time.Duration) {
 			"integration": INTEGRATION,
 		}).Set(duration.Seconds())
 }
+
+// RecordError increments the error counter of an instance for a kind of error,
+// ex: config, auth or api
+func RecordError(instance string, kind string) {
+	const INTEGRATION = "vault-manager"
+
+	reconcileErrorCounter.With(
+		prometheus.Labels{
+			"shard_id":    instance,
+			"integration": INTEGRATION,
+			"kind":        kind,
+		}).Inc()
+}
+
+// RecordDeletionBudgetExceeded increments the counter of reconciles of an instance aborted
+// as a top-level configuration exceeded its deletion budget
+func RecordDeletionBudgetExceeded(instance, toplevel string) {
+	const INTEGRATION = "vault-manager"
+
+	deletionBudgetCounter.With(
+		prometheus.Labels{
+			"shard_id":    instance,
+			"integration": INTEGRATION,
+			"toplevel":    toplevel,
+		}).Inc()
+}
+
+// RecordConfigAge sets the age of the desired state reconciled by the current run
+func RecordConfigAge(provider string, age time.Duration) {
+	const INTEGRATION = "vault-manager"
+
+	configAgeGauge.With(
+		prometheus.Labels{
+			"integration": INTEGRATION,
+			"provider":    provider,
+		}).Set(age.Seconds())
+}
+
+// RecordToplevel records the duration of applying a top-level configuration to an instance
+// and the number of changes planned, keyed by action, ex: write, update or delete. planned
+// changes are counted as applied as well when applied is true
+func RecordToplevel(instance, toplevel string, duration time.Duration, changes map[string]int, applied bool) {
+	const INTEGRATION = "vault-manager"
+
+	toplevelDurationHistogram.With(
+		prometheus.Labels{
+			"shard_id":    instance,
+			"integration": INTEGRATION,
+			"toplevel":    toplevel,
+		}).Observe(duration.Seconds())
+
+	drift := 0
+	for action, count := range changes {
+		drift += count
+		changesCounter.With(
+			prometheus.Labels{
+				"shard_id":    instance,
+				"integration": INTEGRATION,
+				"toplevel":    toplevel,
+				"action":      action,
+				"phase":       PhasePlanned,
+			}).Add(float64(count))
+		if applied {
+			changesCounter.With(
+				prometheus.Labels{
+					"shard_id":    instance,
+					"integration": INTEGRATION,
+					"toplevel":    toplevel,
+					"action":      action,
+					"phase":       PhaseApplied,
+				}).Add(float64(count))
+		}
+	}
+
+	driftGauge.With(
+		prometheus.Labels{
+			"shard_id":    instance,
+			"integration": INTEGRATION,
+			"toplevel":    toplevel,
+		}).Set(float64(drift))
+}
+
+// RecordVaultRequest records a request sent to an instance, code is the status code
+// of the response
+func RecordVaultRequest(instance, method, code string, duration time.Duration) {
+	const INTEGRATION = "vault-manager"
+
+	vaultRequestCounter.With(
+		prometheus.Labels{
+			"shard_id":    instance,
+			"integration": INTEGRATION,
+			"method":      method,
+			"code":        code,
+		}).Inc()
+
+	vaultRequestDurationHistogram.With(
+		prometheus.Labels{
+			"shard_id":    instance,
+			"integration": INTEGRATION,
+			"method":      method,
+		}).Observe(duration.Seconds())
+}
@@ -3,9 +3,11 @@
 package vault
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/hashicorp/vault/api"
@@ -13,8 +15,8 @@ import (
 )
 
 // attempts to read/proccess a single access credential for a particular vault instance
-func GetVaultSecretField(instanceAddr, path, field, engineVersion string) (string, error) {
-	secret, err := ReadSecret(instanceAddr, path, engineVersion)
+func GetVaultSecretField(ctx context.Context, instanceAddr, path, field, engineVersion string) (string, error) {
+	secret, err := ReadSecret(ctx, instanceAddr, path, engineVersion)
 	if err != nil {
 		return "", err
 	}
@@ -32,21 +34,22 @@ func GetVaultSecretField(instanceAddr, path, field, engineVersion string) (strin
 
 // return proper secret path format based upon kv version
 // kv v2 api inserts /data/ between the root engine name and remaining path
-func FormatSecretPath(secret string, secretEngine string) string {
+func FormatSecretPath(secret string, secretEngine string) (string, error) {
 	if secretEngine == KV_V2 {
 		sliced := strings.SplitN(secret, "/", 2)
 		if len(sliced) < 2 {
-			log.Fatal("[Vault Instance] Error processessing kv_v2 secret path")
+			return "", NewConfigError("failed to process kv_v2 secret path",
+				fmt.Errorf("path `%s` does not include a secrets engine", secret))
 		}
-		return fmt.Sprintf("%s/data/%s", sliced[0], sliced[1])
+		return fmt.Sprintf("%s/data/%s", sliced[0], sliced[1]), nil
 	} else {
-		return secret
+		return secret, nil
 	}
 }
 
 // write secret to vault
-func WriteSecret(instanceAddr, secretPath, engineVersion string, secretData map[string]interface{}) error {
-	dataExists, err := DataInSecret(instanceAddr, secretData, secretPath, engineVersion)
+func WriteSecret(ctx context.Context, instanceAddr, secretPath, engineVersion string, secretData map[string]interface{}) error {
+	dataExists, err := DataInSecret(ctx, instanceAddr, secretData, secretPath, engineVersion)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     secretPath,
@@ -55,40 +58,58 @@ func WriteSecret(instanceAddr, secretPath, engineVersion string, secretData map[
 		return err
 	}
 	if !dataExists {
-		versionedPath := FormatSecretPath(secretPath, engineVersion)
-		var err error
+		versionedPath, err := FormatSecretPath(secretPath, engineVersion)
+		if err != nil {
+			return err
+		}
+		client, err := getClient(instanceAddr)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := requestContext(ctx)
+		defer cancel()
 		switch engineVersion {
 		case KV_V1:
-			_, err = getClient(instanceAddr).Logical().Write(versionedPath, secretData)
+			_, err = client.Logical().WriteWithContext(ctx, versionedPath, secretData)
 		case KV_V2:
 			// need to wrap data within json with key "data"
 			v2Data := make(map[string]interface{})
 			v2Data["data"] = secretData
-			_, err = getClient(instanceAddr).Logical().Write(versionedPath, v2Data)
+			_, err = client.Logical().WriteWithContext(ctx, versionedPath, v2Data)
 		}
 		if err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"path":     secretPath,
 				"instance": instanceAddr,
 			}).Info("[Vault Client] failed to write Vault secret")
-			return err
+			return &APIError{Instance: instanceAddr, Op: fmt.Sprintf("failed to write secret `%s`", secretPath), Err: err}
 		}
 	}
 	return nil
 }
 
 // read secret from vault and return the secret map
-func ReadSecret(instanceAddr, secretPath, engineVersion string) (map[string]interface{}, error) {
-	versionedPath := FormatSecretPath(secretPath, engineVersion)
+func ReadSecret(ctx context.Context, instanceAddr, secretPath, engineVersion string) (map[string]interface{}, error) {
+	versionedPath, err := FormatSecretPath(secretPath, engineVersion)
+	if err != nil {
+		return nil, err
+	}
 	// vault manager does not support reverting and should always reference latest data within a-i
 	// therefore, secret version is not specified for KV V2 secrets
-	raw, err := getClient(instanceAddr).Logical().Read(versionedPath)
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	raw, err := client.Logical().ReadWithContext(ctx, versionedPath)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":          secretPath,
 			"instance":      instanceAddr,
 			"engineVersion": engineVersion,
-		}).Fatal("[Vault Client] failed to read Vault secret")
+		}).Info("[Vault Client] failed to read Vault secret")
+		return nil, &APIError{Instance: instanceAddr, Op: fmt.Sprintf("failed to read secret `%s`", secretPath), Err: err}
 	}
 	if raw == nil {
 		return nil, nil
@@ -122,51 +143,75 @@ func ReadSecret(instanceAddr, secretPath, engineVersion string) (map[string]inte
 }
 
 // list secrets
-func ListSecrets(instanceAddr string, path string) (*api.Secret, error) {
-	secretsList, err := getClient(instanceAddr).Logical().List(path)
+func ListSecrets(ctx context.Context, instanceAddr string, path string) (*api.Secret, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	secretsList, err := client.Logical().ListWithContext(ctx, path)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"instance": instanceAddr,
 		}).Info("[Vault Client] failed to list Vault secrets")
-		return nil, errors.New("failed to list secrets")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list Vault secrets", Err: err}
 	}
 	return secretsList, nil
 }
 
 // delete secret from vault
-func DeleteSecret(instanceAddr string, secretPath string) error {
-	_, err := getClient(instanceAddr).Logical().Delete(secretPath)
+func DeleteSecret(ctx context.Context, instanceAddr string, secretPath string) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	_, err = client.Logical().DeleteWithContext(ctx, secretPath)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     secretPath,
 			"instance": instanceAddr,
 		}).Info("[Vault Client] failed to delete Vault secret")
-		return errors.New("failed to delete secret")
+		return &APIError{Instance: instanceAddr, Op: "failed to delete Vault secret", Err: err}
 	}
 	return nil
 }
 
 // list existing enabled Audits Devices.
-func ListAuditDevices(instanceAddr string) (map[string]*api.Audit, error) {
-	enabledAuditDevices, err := getClient(instanceAddr).Sys().ListAudit()
+func ListAuditDevices(ctx context.Context, instanceAddr string) (map[string]*api.Audit, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	enabledAuditDevices, err := client.Sys().ListAuditWithContext(ctx)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": instanceAddr,
 		}).Info("[Vault Audit] failed to list audit devices")
-		return nil, errors.New("failed to list audit devices")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list audit devices", Err: err}
 	}
 	return enabledAuditDevices, nil
 }
 
 // enable audit device with options
-func EnableAuditDevice(instanceAddr, path string, options *api.EnableAuditOptions) error {
-	if err := getClient(instanceAddr).Sys().EnableAuditWithOptions(path, options); err != nil {
+func EnableAuditDevice(ctx context.Context, instanceAddr, path string, options *api.EnableAuditOptions) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().EnableAuditWithOptionsWithContext(ctx, path, options); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"instance": instanceAddr,
 		}).Info("[Vault Audit] failed to enable audit device")
-		return errors.New("failed to enable audit device")
+		return &APIError{Instance: instanceAddr, Op: "failed to enable audit device", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -176,13 +221,19 @@ func EnableAuditDevice(instanceAddr, path string, options *api.EnableAuditOption
 }
 
 // disable audit device
-func DisableAuditDevice(instanceAddr string, path string) error {
-	if err := getClient(instanceAddr).Sys().DisableAudit(path); err != nil {
+func DisableAuditDevice(ctx context.Context, instanceAddr string, path string) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().DisableAuditWithContext(ctx, path); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"instance": instanceAddr,
 		}).Info("[Vault Audit] failed to disable audit device")
-		return errors.New("failed to disable audit device")
+		return &APIError{Instance: instanceAddr, Op: "failed to disable audit device", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -192,26 +243,38 @@ func DisableAuditDevice(instanceAddr string, path string) error {
 }
 
 // list existing auth backends
-func ListAuthBackends(instanceAddr string) (map[string]*api.AuthMount, error) {
-	existingAuthMounts, err := getClient(instanceAddr).Sys().ListAuth()
+func ListAuthBackends(ctx context.Context, instanceAddr string) (map[string]*api.AuthMount, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	existingAuthMounts, err := client.Sys().ListAuthWithContext(ctx)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": instanceAddr,
 		}).Info("[Vault Auth] failed to list auth backends")
-		return nil, errors.New("failed to list auth backends")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list auth backends", Err: err}
 	}
 	return existingAuthMounts, nil
 }
 
 // enable auth backend
-func EnableAuthWithOptions(instanceAddr string, path string, options *api.EnableAuthOptions) error {
-	if err := getClient(instanceAddr).Sys().EnableAuthWithOptions(path, options); err != nil {
+func EnableAuthWithOptions(ctx context.Context, instanceAddr string, path string, options *api.EnableAuthOptions) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().EnableAuthWithOptionsWithContext(ctx, path, options); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"type":     options.Type,
 			"instance": instanceAddr,
 		}).Info("[Vault Auth] failed to enable auth backend")
-		return errors.New("failed to enable auth backend")
+		return &APIError{Instance: instanceAddr, Op: "failed to enable auth backend", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -221,14 +284,42 @@ func EnableAuthWithOptions(instanceAddr string, path string, options *api.Enable
 	return nil
 }
 
+// tune auth backend
+func TuneAuth(ctx context.Context, instanceAddr string, path string, config api.MountConfigInput) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().TuneMountWithContext(ctx, filepath.Join("auth", path), config); err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"path":     path,
+			"instance": instanceAddr,
+		}).Info("[Vault Auth] failed to tune auth backend")
+		return &APIError{Instance: instanceAddr, Op: "failed to tune auth backend", Err: err}
+	}
+	log.WithFields(log.Fields{
+		"path":     path,
+		"instance": instanceAddr,
+	}).Info("[Vault Auth] successfully tuned auth backend")
+	return nil
+}
+
 // disable auth backend
-func DisableAuth(instanceAddr string, path string) error {
-	if err := getClient(instanceAddr).Sys().DisableAuth(path); err != nil {
+func DisableAuth(ctx context.Context, instanceAddr string, path string) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().DisableAuthWithContext(ctx, path); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"instance": instanceAddr,
 		}).Info("[Vault Auth] failed to disable auth backend")
-		return errors.New("failed to disable auth backend")
+		return &APIError{Instance: instanceAddr, Op: "failed to disable auth backend", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -238,39 +329,57 @@ func DisableAuth(instanceAddr string, path string) error {
 }
 
 // returns a list of existing policy names for a specific instance
-func ListVaultPolicies(instanceAddr string) ([]string, error) {
-	existingPolicyNames, err := getClient(instanceAddr).Sys().ListPolicies()
+func ListVaultPolicies(ctx context.Context, instanceAddr string) ([]string, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	existingPolicyNames, err := client.Sys().ListPoliciesWithContext(ctx)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": instanceAddr,
 		}).Info("[Vault Policy] failed to list existing policies")
-		return nil, errors.New("[Vault Policy] failed to list existing policies")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list existing policies", Err: err}
 	}
 	return existingPolicyNames, nil
 }
 
 // get vault policy name
-func GetVaultPolicy(instanceAddr string, name string) (string, error) {
-	policy, err := getClient(instanceAddr).Sys().GetPolicy(name)
+func GetVaultPolicy(ctx context.Context, instanceAddr string, name string) (string, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	policy, err := client.Sys().GetPolicyWithContext(ctx, name)
 	if err != nil {
 		log.WithError(err).WithFields(
 			log.Fields{
 				"name":     name,
 				"instance": instanceAddr,
 			}).Info("[Vault Policy] failed to get existing Vault policy")
-		return "", err
+		return "", &APIError{Instance: instanceAddr, Op: "failed to get existing Vault policy", Err: err}
 	}
 	return policy, nil
 }
 
 // put vault policy
-func PutVaultPolicy(instanceAddr string, name string, rules string) error {
-	if err := getClient(instanceAddr).Sys().PutPolicy(name, rules); err != nil {
+func PutVaultPolicy(ctx context.Context, instanceAddr string, name string, rules string) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().PutPolicyWithContext(ctx, name, rules); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"name":     name,
 			"instance": instanceAddr,
 		}).Info("[Vault Policy] failed to write policy to Vault instance")
-		return err
+		return &APIError{Instance: instanceAddr, Op: "failed to write policy to Vault instance", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"name":     name,
@@ -280,13 +389,19 @@ func PutVaultPolicy(instanceAddr string, name string, rules string) error {
 }
 
 // delete vault policy
-func DeleteVaultPolicy(instanceAddr string, name string) error {
-	if err := getClient(instanceAddr).Sys().DeletePolicy(name); err != nil {
+func DeleteVaultPolicy(ctx context.Context, instanceAddr string, name string) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().DeletePolicyWithContext(ctx, name); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"name":     name,
 			"instance": instanceAddr,
 		}).Info("[Vault Policy] failed to delete vault policy")
-		return err
+		return &APIError{Instance: instanceAddr, Op: "failed to delete vault policy", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"name":     name,
@@ -296,25 +411,37 @@ func DeleteVaultPolicy(instanceAddr string, name string) error {
 }
 
 // return secret engines
-func ListSecretsEngines(instanceAddr string) (map[string]*api.MountOutput, error) {
-	existingMounts, err := getClient(instanceAddr).Sys().ListMounts()
+func ListSecretsEngines(ctx context.Context, instanceAddr string) (map[string]*api.MountOutput, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	existingMounts, err := client.Sys().ListMountsWithContext(ctx)
 	if err != nil {
 		log.WithError(err).WithField("instance", instanceAddr).Info(
 			"[Vault Secrets engine] failed to list Vault secrets engines")
-		return nil, err
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list Vault secrets engines", Err: err}
 	}
 	return existingMounts, nil
 }
 
 // enable secrets engine
-func EnableSecretsEngine(instanceAddr string, path string, mount *api.MountInput) error {
-	if err := getClient(instanceAddr).Sys().Mount(path, mount); err != nil {
+func EnableSecretsEngine(ctx context.Context, instanceAddr string, path string, mount *api.MountInput) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().MountWithContext(ctx, path, mount); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"type":     mount.Type,
 			"instance": instanceAddr,
 		}).Info("[Vault Secrets engine] failed to enable secrets-engine")
-		return err
+		return &APIError{Instance: instanceAddr, Op: "failed to enable secrets-engine", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -325,13 +452,19 @@ func EnableSecretsEngine(instanceAddr string, path string, mount *api.MountInput
 }
 
 // update secrets engine
-func UpdateSecretsEngine(instanceAddr string, path string, config api.MountConfigInput) error {
-	if err := getClient(instanceAddr).Sys().TuneMount(path, config); err != nil {
+func UpdateSecretsEngine(ctx context.Context, instanceAddr string, path string, config api.MountConfigInput) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().TuneMountWithContext(ctx, path, config); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"instance": instanceAddr,
 		}).Info("[Vault Secrets engine] failed to update secrets-engine")
-		return err
+		return &APIError{Instance: instanceAddr, Op: "failed to update secrets-engine", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -341,13 +474,19 @@ func UpdateSecretsEngine(instanceAddr string, path string, config api.MountConfi
 }
 
 // disable secrets engine
-func DisableSecretsEngine(instanceAddr string, path string) error {
-	if err := getClient(instanceAddr).Sys().Unmount(path); err != nil {
+func DisableSecretsEngine(ctx context.Context, instanceAddr string, path string) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	if err := client.Sys().UnmountWithContext(ctx, path); err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     path,
 			"instance": instanceAddr,
 		}).Info("[Vault Secrets engine] failed to disable secrets-engine")
-		return err
+		return &APIError{Instance: instanceAddr, Op: "failed to disable secrets-engine", Err: err}
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -357,36 +496,55 @@ func DisableSecretsEngine(instanceAddr string, path string) error {
 }
 
 // GetVaultVersion returns the vault server version
-func GetVaultVersion(instanceAddr string) (string, error) {
-	info, err := getClient(instanceAddr).Sys().Health()
+func GetVaultVersion(ctx context.Context, instanceAddr string) (string, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	info, err := client.Sys().HealthWithContext(ctx)
 	if err != nil {
 		log.WithError(err).WithField("instance", instanceAddr).Info(
 			"[Vault System] failed to retrieve vault system information")
-		return "", err
+		return "", &APIError{Instance: instanceAddr, Op: "failed to retrieve vault system information", Err: err}
 	}
 	return info.Version, nil
 }
 
-func ListEntities(instanceAddr string) (map[string]interface{}, error) {
-	existingEntities, err := getClient(instanceAddr).Logical().List("identity/entity/id")
+func ListEntities(ctx context.Context, instanceAddr string) (map[string]interface{}, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	existingEntities, err := client.Logical().ListWithContext(ctx, "identity/entity/id")
 	if err != nil {
 		log.WithError(err).WithField("instance", instanceAddr).Info(
 			"[Vault Identity] failed to list Vault entities")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list Vault entities", Err: err}
 	}
 	if existingEntities == nil {
-		return nil, err
+		return nil, nil
 	}
 	return existingEntities.Data, nil
 }
 
-func GetEntityInfo(instanceAddr string, name string) (map[string]interface{}, error) {
-	entity, err := getClient(instanceAddr).Logical().Read(fmt.Sprintf("identity/entity/name/%s", name))
+func GetEntityInfo(ctx context.Context, instanceAddr string, name string) (map[string]interface{}, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	entity, err := client.Logical().ReadWithContext(ctx, fmt.Sprintf("identity/entity/name/%s", name))
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": instanceAddr,
 			"name":     name,
 		}).Info("[Vault Identity] failed to get entity info")
-		return nil, err
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to get entity info", Err: err}
 	}
 	if entity == nil {
 		return nil, nil
@@ -394,36 +552,61 @@ func GetEntityInfo(instanceAddr string, name string) (map[string]interface{}, er
 	return entity.Data, nil
 }
 
-func GetEntityAliasInfo(instanceAddr string, id string) (map[string]interface{}, error) {
-	entityAlias, err := getClient(instanceAddr).Logical().Read(fmt.Sprintf("identity/entity-alias/id/%s", id))
+func GetEntityAliasInfo(ctx context.Context, instanceAddr string, id string) (map[string]interface{}, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	entityAlias, err := client.Logical().ReadWithContext(ctx, fmt.Sprintf("identity/entity-alias/id/%s", id))
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": instanceAddr,
 			"id":       id,
 		}).Info("[Vault Identity] failed to get info for entity alias")
-		return nil, err
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to get info for entity alias", Err: err}
+	}
+	if entityAlias == nil {
+		return nil, &APIError{
+			Instance: instanceAddr,
+			Op:       "failed to get info for entity alias",
+			Err:      fmt.Errorf("entity alias `%s` not found", id),
+		}
 	}
 	return entityAlias.Data, nil
 }
 
-func WriteEntityAlias(instanceAddr string, secretPath string, secretData map[string]interface{}) error {
-	_, err := getClient(instanceAddr).Logical().Write(secretPath, secretData)
+func WriteEntityAlias(ctx context.Context, instanceAddr string, secretPath string, secretData map[string]interface{}) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	_, err = client.Logical().WriteWithContext(ctx, secretPath, secretData)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     secretPath,
 			"instance": instanceAddr,
 		}).Info("[Vault Client] failed to write entity-alias secret")
-		return err
+		return &APIError{Instance: instanceAddr, Op: "failed to write entity-alias secret", Err: err}
 	}
 	return nil
 }
 
-func ListGroups(instanceAddr string) (map[string]interface{}, error) {
-	existingGroups, err := getClient(instanceAddr).Logical().List("identity/group/id")
+func ListGroups(ctx context.Context, instanceAddr string) (map[string]interface{}, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	existingGroups, err := client.Logical().ListWithContext(ctx, "identity/group/id")
 	if err != nil {
 		log.WithError(err).WithField("instance", instanceAddr).Info(
 			"[Vault Group] failed to list Vault groups")
-		return nil, err
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list Vault groups", Err: err}
 	}
 	if existingGroups == nil {
 		return nil, nil
@@ -431,14 +614,20 @@ func ListGroups(instanceAddr string) (map[string]interface{}, error) {
 	return existingGroups.Data, nil
 }
 
-func GetGroupInfo(instanceAddr string, name string) (map[string]interface{}, error) {
-	entity, err := getClient(instanceAddr).Logical().Read(fmt.Sprintf("identity/group/name/%s", name))
+func GetGroupInfo(ctx context.Context, instanceAddr string, name string) (map[string]interface{}, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	entity, err := client.Logical().ReadWithContext(ctx, fmt.Sprintf("identity/group/name/%s", name))
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": instanceAddr,
 			"name":     name,
 		}).Info("[Vault Group] failed to get info for group")
-		return nil, err
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to get info for group", Err: err}
 	}
 	if entity == nil {
 		return nil, nil
@@ -446,26 +635,69 @@ func GetGroupInfo(instanceAddr string, name string) (map[string]interface{}, err
 	return entity.Data, nil
 }
 
+func ListGroupAliases(ctx context.Context, instanceAddr string) (map[string]interface{}, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	existingAliases, err := client.Logical().ListWithContext(ctx, "identity/group-alias/id")
+	if err != nil {
+		log.WithError(err).WithField("instance", instanceAddr).Info(
+			"[Vault Group] failed to list Vault group aliases")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to list Vault group aliases", Err: err}
+	}
+	if existingAliases == nil {
+		return nil, nil
+	}
+	return existingAliases.Data, nil
+}
+
+func WriteGroupAlias(ctx context.Context, instanceAddr string, secretPath string, secretData map[string]interface{}) error {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	_, err = client.Logical().WriteWithContext(ctx, secretPath, secretData)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"path":     secretPath,
+			"instance": instanceAddr,
+		}).Info("[Vault Client] failed to write group-alias secret")
+		return &APIError{Instance: instanceAddr, Op: "failed to write group-alias secret", Err: err}
+	}
+	return nil
+}
+
 // "write" empty secret to approle secret-id endpoint in order to generate new secret_id
 // https://www.vaultproject.io/docs/auth/approle#via-the-api-1
-func GenerateApproleSecretID(instanceAddr, secretPath string) (*api.Secret, error) {
-	secret, err := getClient(instanceAddr).Logical().Write(secretPath, map[string]interface{}{})
+func GenerateApproleSecretID(ctx context.Context, instanceAddr, secretPath string) (*api.Secret, error) {
+	client, err := getClient(instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+	secret, err := client.Logical().WriteWithContext(ctx, secretPath, map[string]interface{}{})
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"path":     secretPath,
 			"instance": instanceAddr,
 		}).Info("[Vault Client] failed to write Vault secret")
-		return nil, errors.New("failed to write secret")
+		return nil, &APIError{Instance: instanceAddr, Op: "failed to write Vault secret", Err: err}
 	}
 	return secret, nil
 }
 
-func mustGetenv(name string) string {
+func requireEnv(name string) (string, error) {
 	env := os.Getenv(name)
 	if env == "" {
-		log.WithField("env", name).Fatal("required environment variable is unset")
+		return "", NewConfigError("required environment variable is unset", fmt.Errorf("`%s`", name))
 	}
-	return env
+	return env, nil
 }
 
 func defaultGetenv(name, defaultName string) string {
@@ -0,0 +1,88 @@
+package vault
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// registers a client of a vault instance that never responds
+func hangingInstance(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	config.MaxRetries = 0
+	client, err := api.NewClient(config)
+	require.NoError(t, err)
+	registry.Load().set(server.URL, client)
+	return server.URL
+}
+
+func TestRequestTimeout(t *testing.T) {
+	address := hangingInstance(t)
+	SetRequestTimeout(50 * time.Millisecond)
+	defer SetRequestTimeout(0)
+
+	_, err := ListVaultPolicies(context.Background(), address)
+	require.Error(t, err)
+	require.Equal(t, TimeoutErrorKind, ErrorKind(err))
+}
+
+func TestRequestCancelled(t *testing.T) {
+	address := hangingInstance(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := ReadSecret(ctx, address, "secret/a", KV_V1)
+	require.Error(t, err)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, CanceledErrorKind, ErrorKind(err))
+}
+
+func TestInstrumentedRequests(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	require.NoError(t, err)
+	instrument(config, server.URL)
+	registry.Load().set(server.URL, client)
+
+	_, err = GetVaultPolicy(context.Background(), server.URL, "a")
+	require.Error(t, err)
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	requests := map[string]float64{}
+	for _, family := range families {
+		if family.GetName() != "vault_manager_vault_requests_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["shard_id"] == server.URL {
+				requests[labels["method"]+" "+labels["code"]] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, map[string]float64{"GET 403": 1}, requests)
+}
@@ -0,0 +1,170 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Differ is implemented by items that can describe, field by field, how they
+// differ from an existing item of the same kind.
+type Differ interface {
+	Diff(interface{}) []string
+}
+
+// number of unchanged lines surrounding each change within a unified diff
+const diffContextLines = 3
+
+// DiffItem returns the differences between item and the existing item
+// sharing its key. Nil is returned when no such item exists or when item
+// does not implement Differ.
+func DiffItem(item Item, existing []Item) []string {
+	d, ok := item.(Differ)
+	if !ok {
+		return nil
+	}
+	for _, e := range existing {
+		if e.Key() == item.Key() {
+			return d.Diff(e)
+		}
+	}
+	return nil
+}
+
+// DiffField returns a single `name: old -> new` line when the values differ.
+func DiffField(name string, existing, desired interface{}) []string {
+	if fmt.Sprintf("%v", existing) == fmt.Sprintf("%v", desired) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: %v -> %v", name, existing, desired)}
+}
+
+// DiffOptions returns a `key: old -> new` line for every option that differs
+// between two sets of options mappings. Values are compared the same way as
+// in OptionsEqual and the result is sorted by key.
+func DiffOptions(existing, desired map[string]interface{}) []string {
+	keys := make(map[string]bool)
+	for k := range existing {
+		keys[k] = true
+	}
+	for k := range desired {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	diff := []string{}
+	for _, k := range sorted {
+		x, xok := existing[k]
+		y, yok := desired[k]
+		switch {
+		case !xok:
+			diff = append(diff, fmt.Sprintf("%s: <unset> -> %v", k, y))
+		case !yok:
+			diff = append(diff, fmt.Sprintf("%s: %v -> <unset>", k, x))
+		case !optionEqual(k, x, y):
+			diff = append(diff, fmt.Sprintf("%s: %v -> %v", k, x, y))
+		}
+	}
+	return diff
+}
+
+// DiffLines returns a unified diff between the existing and desired text.
+// Removed lines are prefixed with "-", added lines with "+" and unchanged
+// context lines with a space.
+func DiffLines(existing, desired string) []string {
+	a := splitLines(existing)
+	b := splitLines(desired)
+
+	// longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type op struct {
+		kind byte
+		line string
+		ai   int
+		bi   int
+	}
+	ops := []op{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j], i, j})
+			j++
+		}
+	}
+
+	// group changes into hunks surrounded by context lines
+	diff := []string{}
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			start++
+			continue
+		}
+		from := start - diffContextLines
+		if from < 0 {
+			from = 0
+		}
+		to := start
+		for k := start; k < len(ops) && k-to <= 2*diffContextLines; k++ {
+			if ops[k].kind != ' ' {
+				to = k
+			}
+		}
+		end := to + diffContextLines + 1
+		if end > len(ops) {
+			end = len(ops)
+		}
+
+		removed, added := 0, 0
+		for _, o := range ops[from:end] {
+			if o.kind != '+' {
+				removed++
+			}
+			if o.kind != '-' {
+				added++
+			}
+		}
+		diff = append(diff, fmt.Sprintf("@@ -%d,%d +%d,%d @@",
+			ops[from].ai+1, removed, ops[from].bi+1, added))
+		for _, o := range ops[from:end] {
+			diff = append(diff, string(o.kind)+o.line)
+		}
+		start = end
+	}
+	return diff
+}
+
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
+}
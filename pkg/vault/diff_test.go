@@ -0,0 +1,78 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffOptions(t *testing.T) {
+	table := []struct {
+		description string
+		existing    map[string]interface{}
+		desired     map[string]interface{}
+		expected    []string
+	}{
+		{
+			description: "equal options produce no diff",
+			existing:    map[string]interface{}{"token_ttl": "3600", "x": "x"},
+			desired:     map[string]interface{}{"token_ttl": "1h", "x": "x"},
+			expected:    []string{},
+		},
+		{
+			description: "changed ttl is reported",
+			existing:    map[string]interface{}{"token_ttl": "1h"},
+			desired:     map[string]interface{}{"token_ttl": "2h"},
+			expected:    []string{"token_ttl: 1h -> 2h"},
+		},
+		{
+			description: "added and removed keys are reported in order",
+			existing:    map[string]interface{}{"b": "1"},
+			desired:     map[string]interface{}{"a": "1"},
+			expected:    []string{"a: <unset> -> 1", "b: 1 -> <unset>"},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.description, func(t *testing.T) {
+			require.Equal(t, tt.expected, DiffOptions(tt.existing, tt.desired))
+		})
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	table := []struct {
+		description string
+		existing    string
+		desired     string
+		expected    []string
+	}{
+		{
+			description: "identical text produces no diff",
+			existing:    "a\nb\n",
+			desired:     "a\nb",
+			expected:    []string{},
+		},
+		{
+			description: "changed line is shown with context",
+			existing:    "a\nb\nc",
+			desired:     "a\nx\nc",
+			expected:    []string{"@@ -1,3 +1,3 @@", " a", "-b", "+x", " c"},
+		},
+		{
+			description: "distant changes are split into hunks",
+			existing:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10",
+			desired:     "0\n2\n3\n4\n5\n6\n7\n8\n9\n11",
+			expected: []string{
+				"@@ -1,4 +1,4 @@", "-1", "+0", " 2", " 3", " 4",
+				"@@ -7,4 +7,4 @@", " 7", " 8", " 9", "-10", "+11",
+			},
+		},
+	}
+
+	for _, tt := range table {
+		t.Run(tt.description, func(t *testing.T) {
+			require.Equal(t, tt.expected, DiffLines(tt.existing, tt.desired))
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ConfigError indicates that the desired state or the environment of
+// vault-manager is invalid. Retrying without changes will not succeed.
+type ConfigError struct {
+	Op  string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Op, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// AuthError indicates that vault-manager could not authenticate with an instance.
+type AuthError struct {
+	Instance string
+	Op       string
+	Err      error
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("[%s] %s: %v", e.Instance, e.Op, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// APIError indicates that a request to a vault instance failed.
+type APIError struct {
+	Instance string
+	Op       string
+	Err      error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("[%s] %s: %v", e.Instance, e.Op, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// KindError is implemented by errors of other packages that classify themselves,
+// ex: the deletion budget errors of top-level configurations.
+type KindError interface {
+	error
+	Kind() string
+}
+
+// kinds of errors returned by ErrorKind
+const (
+	ConfigErrorKind   = "config"
+	AuthErrorKind     = "auth"
+	APIErrorKind      = "api"
+	TimeoutErrorKind  = "timeout"
+	CanceledErrorKind = "canceled"
+	BudgetErrorKind   = "budget"
+	UnknownErrorKind  = "unknown"
+)
+
+// ErrorKind returns the kind of the first typed error within the chain of err, or of
+// the first error implementing KindError. errors caused by an exceeded deadline are of
+// the timeout kind, errors caused by a cancelled context, ex: on termination, are of the
+// canceled kind
+func ErrorKind(err error) string {
+	var configErr *ConfigError
+	var authErr *AuthError
+	var apiErr *APIError
+	var kindErr KindError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return TimeoutErrorKind
+	case errors.Is(err, context.Canceled):
+		return CanceledErrorKind
+	case errors.As(err, &configErr):
+		return ConfigErrorKind
+	case errors.As(err, &authErr):
+		return AuthErrorKind
+	case errors.As(err, &apiErr):
+		return APIErrorKind
+	case errors.As(err, &kindErr):
+		return kindErr.Kind()
+	default:
+		return UnknownErrorKind
+	}
+}
+
+// NewConfigError returns a ConfigError for a failed operation on the desired state
+func NewConfigError(op string, err error) error {
+	return &ConfigError{Op: op, Err: err}
+}
@@ -0,0 +1,75 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// budgetError classifies itself, as errors of other packages do
+type budgetError struct{}
+
+func (budgetError) Error() string {
+	return "budget exceeded"
+}
+
+func (budgetError) Kind() string {
+	return BudgetErrorKind
+}
+
+func TestErrorKind(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       error
+		expected    string
+	}{
+		{
+			"config error",
+			NewConfigError("failed to decode", errors.New("bad yaml")),
+			ConfigErrorKind,
+		},
+		{
+			"wrapped auth error",
+			fmt.Errorf("reconcile failed: %w", &AuthError{Instance: "https://vault", Op: "login", Err: errors.New("denied")}),
+			AuthErrorKind,
+		},
+		{
+			"api error",
+			&APIError{Instance: "https://vault", Op: "failed to list", Err: errors.New("503")},
+			APIErrorKind,
+		},
+		{
+			"timed out api error",
+			&APIError{Instance: "https://vault", Op: "failed to list", Err: context.DeadlineExceeded},
+			TimeoutErrorKind,
+		},
+		{
+			"cancelled api error",
+			&APIError{Instance: "https://vault", Op: "failed to list", Err: context.Canceled},
+			CanceledErrorKind,
+		},
+		{
+			"wrapped error classifying itself",
+			fmt.Errorf("reconcile failed: %w", budgetError{}),
+			BudgetErrorKind,
+		},
+		{
+			"untyped error",
+			errors.New("something else"),
+			UnknownErrorKind,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, c.expected, ErrorKind(c.given))
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -74,31 +76,88 @@ const (
 	defaultTokenRetrySleep = 250 * time.Millisecond
 )
 
-// global that maps instance addresses to configured vault clients
-// initialization process is triggered by call to GetInstances()
-// GetInstances() is called a single time within main
-var vaultClients map[string]*api.Client
+// How long a single request to a Vault instance may take before it is cancelled.
+// Zero disables the deadline, requests are then only bound by the context passed by callers.
+var requestTimeout atomic.Int64
+
+// SetRequestTimeout sets the deadline of every subsequent request to Vault instances
+func SetRequestTimeout(timeout time.Duration) {
+	requestTimeout.Store(int64(timeout))
+}
+
+// requestContext derives the context of a single request to a Vault instance from ctx
+func requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout := time.Duration(requestTimeout.Load()); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// clientRegistry maps instance addresses to configured vault clients.
+// clients are only added while the registry is initialized by GetInstances(),
+// afterwards the registry is safe to be read by instances reconciled concurrently
+type clientRegistry struct {
+	mutex   sync.RWMutex
+	clients map[string]*api.Client
+	// address of the master instance that holds access credentials and other
+	// secrets referenced by the desired state
+	master string
+}
+
+func newClientRegistry() *clientRegistry {
+	return &clientRegistry{clients: make(map[string]*api.Client)}
+}
+
+func (r *clientRegistry) get(addr string) *api.Client {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.clients[addr]
+}
+
+func (r *clientRegistry) set(addr string, client *api.Client) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clients[addr] = client
+}
+
+func (r *clientRegistry) addresses() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	addresses := make([]string, 0, len(r.clients))
+	for address := range r.clients {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+	return addresses
+}
+
+// registry of the current run, replaced by each call to GetInstances()
+var registry atomic.Pointer[clientRegistry]
+
+func init() {
+	registry.Store(newClientRegistry())
+}
 
 // Utilized to initialize vault instance clients for use by other toplevel integrations
 // returns list of instance addresses being included in reconcile
-func GetInstances(entriesBytes []byte, kubeAuth bool, threadPoolSize int) []string {
+// an error is returned when the master instance cannot be accessed, failures to
+// access other instances only exclude these instances from reconcile
+func GetInstances(ctx context.Context, entriesBytes []byte, kubeAuth bool, threadPoolSize int) ([]string, error) {
 	var instances []Instance
 	if err := yaml.Unmarshal(entriesBytes, &instances); err != nil {
-		log.WithError(err).Fatal("[Vault Instance] failed to decode instance configuration")
+		return nil, NewConfigError("[Vault Instance] failed to decode instance configuration", err)
 	}
 
 	instanceCreds, err := processInstances(instances, kubeAuth)
 	if err != nil {
-		log.WithError(err).Fatal("[Vault Instance] failed to retrieve access credentials")
+		return nil, NewConfigError("[Vault Instance] failed to retrieve access credentials", err)
+	}
+	if err := initClients(ctx, instanceCreds, threadPoolSize); err != nil {
+		return nil, err
 	}
-	initClients(instanceCreds, threadPoolSize)
 
 	// return list of addresses that clients were initialized for
-	addresses := []string{}
-	for address := range vaultClients {
-		addresses = append(addresses, address)
-	}
-	return addresses
+	return registry.Load().addresses(), nil
 }
 
 // generates map of instance addresses to access credentials stored in master vault
@@ -155,37 +214,49 @@ func processInstances(instances []Instance, kubeAuth bool) (map[string]AuthBundl
 	return instanceCreds, nil
 }
 
-// Creates global map of all vault clients defined in a-i
+// Replaces the registry with a new one holding clients of all vault instances defined in a-i
 // This allows reconciliation of multiple vault instances
-func initClients(instanceCreds map[string]AuthBundle, threadPoolSize int) {
-	vaultClients = make(map[string]*api.Client) // THIS IS THE GLOBAL
-	masterAddress := configureMaster(instanceCreds)
+func initClients(ctx context.Context, instanceCreds map[string]AuthBundle, threadPoolSize int) error {
+	r := newClientRegistry()
+	registry.Store(r)
+	masterClient, masterAddress, err := configureMaster(ctx, instanceCreds)
+	if err != nil {
+		return err
+	}
+	r.master = masterAddress
+	r.set(masterAddress, masterClient)
+
 	bwg := utils.NewBoundedWaitGroup(threadPoolSize)
-	var mutex = &sync.Mutex{}
 	// read access credentials for other vault instances and configure clients
 	for addr, bundle := range instanceCreds {
 		// client already configured separately for master
 		if addr != masterAddress {
 			bwg.Add(1)
-			go createClient(addr, masterAddress, bundle, &bwg, mutex)
+			go createClient(ctx, r, addr, masterAddress, bundle, &bwg)
 		}
 	}
 	bwg.Wait()
+	return nil
 }
 
 // configureMaster initializes vault client for the master instance
 // This is the only client that can be configured using environment variables
 // env vars: VAULT_ADDR, VAULT_AUTHTYPE, VAULT_ROLE_ID, VAULT_SECRET_ID, VAULT_TOKEN
-func configureMaster(instanceCreds map[string]AuthBundle) string {
+func configureMaster(ctx context.Context, instanceCreds map[string]AuthBundle) (*api.Client, string, error) {
 	masterVaultCFG := api.DefaultConfig()
-	masterVaultCFG.Address = mustGetenv("VAULT_ADDR")
+	address, err := requireEnv("VAULT_ADDR")
+	if err != nil {
+		return nil, "", err
+	}
+	masterVaultCFG.Address = address
 
 	client, err := api.NewClient(masterVaultCFG)
 	if err != nil {
-		log.WithError(err).Fatal("[Vault Client] failed to initialize master Vault client")
+		return nil, "", NewConfigError("[Vault Client] failed to initialize master Vault client", err)
 	}
+	instrument(masterVaultCFG, address)
 
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), defaultClientLoginTimeout)
+	ctxTimeout, cancel := context.WithTimeout(ctx, defaultClientLoginTimeout)
 	defer cancel()
 
 	masterAuthBundle := instanceCreds[masterVaultCFG.Address]
@@ -193,34 +264,51 @@ func configureMaster(instanceCreds map[string]AuthBundle) string {
 	if len(masterAuthBundle.KubeRoleName) > 0 {
 		err := configureKubeAuthClient(ctxTimeout, client, masterAuthBundle)
 		if err != nil {
-			log.WithError(err).Fatal("[Vault Client] failed to configure master client using Kubernetes authentication")
+			return nil, "", &AuthError{Instance: address,
+				Op: "[Vault Client] failed to configure master client using Kubernetes authentication", Err: err}
 		}
 	} else {
 		authType := defaultGetenv("VAULT_AUTHTYPE", "approle")
 		switch strings.ToLower(authType) {
 		case APPROLE_AUTH:
-			roleID := mustGetenv("VAULT_ROLE_ID")
-			secretID := mustGetenv("VAULT_SECRET_ID")
+			roleID, err := requireEnv("VAULT_ROLE_ID")
+			if err != nil {
+				return nil, "", err
+			}
+			secretID, err := requireEnv("VAULT_SECRET_ID")
+			if err != nil {
+				return nil, "", err
+			}
 
-			err := configureAppRoleAuthClient(ctxTimeout, client, roleID, secretID)
+			err = configureAppRoleAuthClient(ctxTimeout, client, roleID, secretID)
 			if err != nil {
-				log.WithError(err).Fatal("[Vault Client] failed to login to master Vault with AppRole")
+				return nil, "", &AuthError{Instance: address,
+					Op: "[Vault Client] failed to login to master Vault with AppRole", Err: err}
 			}
 		case TOKEN_AUTH:
-			clientToken := mustGetenv("VAULT_TOKEN")
+			clientToken, err := requireEnv("VAULT_TOKEN")
+			if err != nil {
+				return nil, "", err
+			}
 			client.SetToken(clientToken)
 		default:
-			log.WithField("authType", authType).Fatal("[Vault Client] unsupported authentication type")
+			return nil, "", NewConfigError("[Vault Client] unsupported authentication type",
+				fmt.Errorf("`%s`", authType))
 		}
 	}
 
-	vaultClients[masterVaultCFG.Address] = client
-	return masterVaultCFG.Address
+	return client, masterVaultCFG.Address, nil
 }
 
 func configureKubeAuthClient(ctx context.Context, client *api.Client, bundle AuthBundle) error {
-	mount := mustGetenv("KUBE_AUTH_MOUNT")
-	kubeSATokenPath := mustGetenv("KUBE_SA_TOKEN_PATH")
+	mount, err := requireEnv("KUBE_AUTH_MOUNT")
+	if err != nil {
+		return err
+	}
+	kubeSATokenPath, err := requireEnv("KUBE_SA_TOKEN_PATH")
+	if err != nil {
+		return err
+	}
 
 	auth, err := kubernetes.NewKubernetesAuth(
 		bundle.KubeRoleName,
@@ -274,7 +362,7 @@ func login(ctx context.Context, client *api.Client, auth api.AuthMethod) error {
 
 // goroutine support function for initClients()
 // initializes one vault client
-func createClient(addr string, masterAddress string, bundle AuthBundle, bwg *utils.BoundedWaitGroup, mutex *sync.Mutex) {
+func createClient(ctx context.Context, r *clientRegistry, addr string, masterAddress string, bundle AuthBundle, bwg *utils.BoundedWaitGroup) {
 	defer bwg.Done()
 
 	config := api.DefaultConfig()
@@ -285,8 +373,9 @@ func createClient(addr string, masterAddress string, bundle AuthBundle, bwg *uti
 		log.Warnf("SKIPPING ALL RECONCILIATION FOR: %s", addr)
 		return // Skip entire reconciliation for this instance.
 	}
+	instrument(config, addr)
 
-	ctxTimeout, cancel := context.WithTimeout(context.Background(), defaultClientLoginTimeout)
+	ctxTimeout, cancel := context.WithTimeout(ctx, defaultClientLoginTimeout)
 	defer cancel()
 
 	// indicates kube auth should be utilized
@@ -301,9 +390,11 @@ func createClient(addr string, masterAddress string, bundle AuthBundle, bwg *uti
 		accessCreds := make(map[string]string)
 		for _, cred := range bundle.VaultSecrets {
 			// masterAddress hard-coded because all "child" vault access credentials must be pulled from master
-			processedCred, err := GetVaultSecretField(masterAddress, cred.Path, cred.Field, bundle.SecretEngine)
+			processedCred, err := GetVaultSecretField(ctx, masterAddress, cred.Path, cred.Field, bundle.SecretEngine)
 			if err != nil {
-				log.WithError(err).Fatal("[Vault Client] unable to retrieve credentials from master Vault")
+				log.WithError(err).Errorf("[Vault Client] unable to retrieve credentials for `%s` from master Vault", addr)
+				log.Warnf("SKIPPING ALL RECONCILIATION FOR: %s", addr)
+				return // Skip entire reconciliation for this instance.
 			}
 			accessCreds[cred.Name] = processedCred
 		}
@@ -324,23 +415,31 @@ func createClient(addr string, masterAddress string, bundle AuthBundle, bwg *uti
 	}
 
 	// test client
-	_, err = client.Sys().ListAuth()
+	reqCtx, reqCancel := requestContext(ctx)
+	defer reqCancel()
+	_, err = client.Sys().ListAuthWithContext(reqCtx)
 	if err != nil {
 		log.WithError(err).Errorf("[Vault Client] failed to login to `%s`", addr)
 		log.Warnf("SKIPPING ALL RECONCILIATION FOR: %s", addr)
 		return
 	}
 
-	// add new address/client pair to global
-	mutex.Lock()
-	defer mutex.Unlock()
-	vaultClients[addr] = client
+	// add new address/client pair to the registry
+	r.set(addr, client)
+}
+
+// MasterAddress returns the address of the master instance
+func MasterAddress() string {
+	return registry.Load().master
 }
 
 // returns the vault client associated with instance address
-func getClient(instanceAddr string) *api.Client {
-	if vaultClients[instanceAddr] == nil {
-		log.Fatalf("[Vault Client] client does not exist for address: %s", instanceAddr)
+// a client does not exist when authentication with the instance failed
+func getClient(instanceAddr string) (*api.Client, error) {
+	client := registry.Load().get(instanceAddr)
+	if client == nil {
+		return nil, &AuthError{Instance: instanceAddr, Op: "[Vault Client] client does not exist",
+			Err: errors.New("instance was not authenticated")}
 	}
-	return vaultClients[instanceAddr]
+	return client, nil
 }
@@ -0,0 +1,36 @@
+package vault
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/app-sre/vault-manager/pkg/utils"
+	"github.com/hashicorp/vault/api"
+)
+
+// instrumentedTransport records the count, latency and status code of every request
+// sent to a vault instance, including retries and logins
+type instrumentedTransport struct {
+	instance string
+	next     http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	// requests that did not get a response, ex: a timeout or a refused connection
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	utils.RecordVaultRequest(t.instance, req.Method, code, time.Since(start))
+	return resp, err
+}
+
+// instrument records the requests sent by a client created with config.
+// must be called once the client is created, as the transport of config is
+// expected to be an *http.Transport while the client is configured
+func instrument(config *api.Config, instance string) {
+	config.HttpClient.Transport = instrumentedTransport{instance: instance, next: config.HttpClient.Transport}
+}
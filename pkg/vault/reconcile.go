@@ -1,6 +1,8 @@
 package vault
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
@@ -114,22 +116,7 @@ func OptionsEqual(xopts, yopts map[string]interface{}) bool {
 		if !ok {
 			return false
 		}
-
-		// option values that need to be processed as numbers
-		if strings.HasSuffix(k, "ttl") || strings.HasSuffix(k, "period") ||
-			strings.HasSuffix(k, "leeway") || k == "max_age" {
-			if !ttlEqual(fmt.Sprintf("%v", v), fmt.Sprintf("%v", xv)) {
-				return false
-			}
-			continue
-		} else if k == "bound_claims" || k == "claim_mappings" {
-			if reflect.DeepEqual(xv, v) {
-				continue
-			}
-			return false
-		}
-
-		if fmt.Sprintf("%v", v) != fmt.Sprintf("%v", xv) {
+		if !optionEqual(k, xv, v) {
 			return false
 		}
 	}
@@ -137,6 +124,19 @@ func OptionsEqual(xopts, yopts map[string]interface{}) bool {
 	return true
 }
 
+// optionEqual compares the values of a single option.
+func optionEqual(k string, x, y interface{}) bool {
+	// option values that need to be processed as numbers
+	if strings.HasSuffix(k, "ttl") || strings.HasSuffix(k, "period") ||
+		strings.HasSuffix(k, "leeway") || k == "max_age" {
+		return ttlEqual(fmt.Sprintf("%v", y), fmt.Sprintf("%v", x))
+	} else if k == "bound_claims" || k == "claim_mappings" {
+		return reflect.DeepEqual(x, y)
+	}
+
+	return fmt.Sprintf("%v", y) == fmt.Sprintf("%v", x)
+}
+
 func ttlEqual(x, y string) bool {
 	if x == y {
 		return true
@@ -160,6 +160,9 @@ func EqualPathNames(x, y string) bool {
 // ParseDuration parses a string duration from Vault.
 // Defaults to seconds if no unit is found at the end of the string.
 func ParseDuration(duration string) (time.Duration, error) {
+	if duration == "" {
+		return 0, errors.New("empty duration")
+	}
 	lastChar := string([]rune(duration)[len(duration)-1])
 	if strings.ContainsAny(lastChar, "1234567890") {
 		duration += "s"
@@ -169,9 +172,9 @@ func ParseDuration(duration string) (time.Duration, error) {
 }
 
 // DataInSecret compare given data with data stored in the vault secret
-func DataInSecret(instanceAddr string, data map[string]interface{}, path string, version string) (bool, error) {
+func DataInSecret(ctx context.Context, instanceAddr string, data map[string]interface{}, path string, version string) (bool, error) {
 	// read desired secret
-	secret, err := ReadSecret(instanceAddr, path, version)
+	secret, err := ReadSecret(ctx, instanceAddr, path, version)
 	if err != nil {
 		return false, err
 	}
@@ -180,7 +183,7 @@ func DataInSecret(instanceAddr string, data map[string]interface{}, path string,
 	}
 	for k, v := range data {
 		if strings.HasSuffix(k, "ttl") || strings.HasSuffix(k, "period") {
-			dur, err := ParseDuration(v.(string))
+			dur, err := ParseDuration(fmt.Sprintf("%v", v))
 			if err != nil {
 				log.WithError(err).WithField("option", k).Info("failed to parse duration from data")
 				return false, err
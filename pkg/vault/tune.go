@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// TuneOptions returns the tune attributes of an existing mount as an options
+// mapping. Only attributes present within desired are included so that
+// attributes omitted from a definition are left untouched.
+func TuneOptions(cfg api.MountConfigOutput, desired map[string]interface{}) map[string]interface{} {
+	existing := map[string]interface{}{
+		"default_lease_ttl":            cfg.DefaultLeaseTTL,
+		"max_lease_ttl":                cfg.MaxLeaseTTL,
+		"listing_visibility":           cfg.ListingVisibility,
+		"audit_non_hmac_request_keys":  stringsOrEmpty(cfg.AuditNonHMACRequestKeys),
+		"audit_non_hmac_response_keys": stringsOrEmpty(cfg.AuditNonHMACResponseKeys),
+		"passthrough_request_headers":  stringsOrEmpty(cfg.PassthroughRequestHeaders),
+		"allowed_response_headers":     stringsOrEmpty(cfg.AllowedResponseHeaders),
+		"token_type":                   cfg.TokenType,
+	}
+	opts := make(map[string]interface{}, len(desired))
+	for k := range desired {
+		if v, ok := existing[k]; ok {
+			opts[k] = v
+		}
+	}
+	return opts
+}
+
+// MountConfigInput converts a tune options mapping into the payload expected
+// by the sys/mounts/<path>/tune endpoint.
+func MountConfigInput(opts map[string]interface{}) (api.MountConfigInput, error) {
+	input := api.MountConfigInput{}
+	for k, v := range opts {
+		var err error
+		switch k {
+		case "default_lease_ttl":
+			input.DefaultLeaseTTL = fmt.Sprintf("%v", v)
+		case "max_lease_ttl":
+			input.MaxLeaseTTL = fmt.Sprintf("%v", v)
+		case "listing_visibility":
+			input.ListingVisibility = fmt.Sprintf("%v", v)
+		case "token_type":
+			input.TokenType = fmt.Sprintf("%v", v)
+		case "audit_non_hmac_request_keys":
+			input.AuditNonHMACRequestKeys, err = toStrings(k, v)
+		case "audit_non_hmac_response_keys":
+			input.AuditNonHMACResponseKeys, err = toStrings(k, v)
+		case "passthrough_request_headers":
+			input.PassthroughRequestHeaders, err = toStrings(k, v)
+		case "allowed_response_headers":
+			input.AllowedResponseHeaders, err = toStrings(k, v)
+		default:
+			return input, fmt.Errorf("unsupported tune attribute `%s`", k)
+		}
+		if err != nil {
+			return input, err
+		}
+	}
+	return input, nil
+}
+
+// WithoutNilOptions returns a copy of opts without attributes that were
+// omitted from a definition and therefore assigned nil by graphql.
+func WithoutNilOptions(opts map[string]interface{}) map[string]interface{} {
+	pruned := make(map[string]interface{}, len(opts))
+	for k, v := range opts {
+		if v != nil {
+			pruned[k] = v
+		}
+	}
+	return pruned
+}
+
+// converts a list option decoded from yaml into a slice of strings
+func toStrings(key string, v interface{}) ([]string, error) {
+	switch t := v.(type) {
+	case nil:
+		return []string{}, nil
+	case []string:
+		return t, nil
+	case []interface{}:
+		strs := make([]string, 0, len(t))
+		for _, s := range t {
+			strs = append(strs, fmt.Sprintf("%v", s))
+		}
+		return strs, nil
+	default:
+		return nil, fmt.Errorf("failed to convert `%s` to a list of strings", key)
+	}
+}
+
+func stringsOrEmpty(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,43 @@
+package vault
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTuneOptionsEqualDesired(t *testing.T) {
+	desired := map[string]interface{}{
+		"default_lease_ttl":           "1h",
+		"audit_non_hmac_request_keys": []interface{}{"a", "b"},
+		"listing_visibility":          "unauth",
+	}
+	existing := TuneOptions(api.MountConfigOutput{
+		DefaultLeaseTTL:         3600,
+		MaxLeaseTTL:             7200,
+		AuditNonHMACRequestKeys: []string{"a", "b"},
+		ListingVisibility:       "unauth",
+	}, desired)
+
+	// attributes omitted from desired are not reconciled
+	require.NotContains(t, existing, "max_lease_ttl")
+	require.True(t, OptionsEqual(existing, desired))
+}
+
+func TestMountConfigInput(t *testing.T) {
+	input, err := MountConfigInput(map[string]interface{}{
+		"max_lease_ttl":               "2h",
+		"token_type":                  "batch",
+		"passthrough_request_headers": []interface{}{"X-Foo"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, api.MountConfigInput{
+		MaxLeaseTTL:               "2h",
+		TokenType:                 "batch",
+		PassthroughRequestHeaders: []string{"X-Foo"},
+	}, input)
+
+	_, err = MountConfigInput(map[string]interface{}{"unknown": "x"})
+	require.Error(t, err)
+}
@@ -25,3 +25,22 @@ load ../helpers
     [[ "${output}" != *"file/"* ]]
 
 }
+
+@test "test vault-manager plan-output flag" {
+    #
+    # CASE: check plan-output flag
+    #
+    export GRAPHQL_QUERY_FILE=/tests/fixtures/audit/enable_audit_device.graphql
+
+    run vault-manager -dry-run -plan-output=/tmp/plan.json
+    [ "$status" -eq 0 ]
+
+    # plan contains the audit device planned for both instances
+    run jq -r '.changes[] | select(.toplevel == "vault_audit_backends" and .action == "write") | .instance' /tmp/plan.json
+    [ "$status" -eq 0 ]
+    [[ "${output}" == *"${PRIMARY_VAULT_URL}"* ]]
+    [[ "${output}" == *"${SECONDARY_VAULT_URL}"* ]]
+
+    run jq -r '.dry_run' /tmp/plan.json
+    [[ "${output}" == "true" ]]
+}
@@ -3,6 +3,8 @@
 package audit
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/app-sre/vault-manager/pkg/utils"
@@ -15,11 +17,13 @@ import (
 )
 
 type entry struct {
-	Path        string            `yaml:"_path"`
-	Type        string            `yaml:"type"`
-	Description string            `yaml:"description"`
-	Instance    vault.Instance    `yaml:"instance"`
-	Options     map[string]string `yaml:"options"`
+	Path        string             `yaml:"_path"`
+	Type        string             `yaml:"type"`
+	Description string             `yaml:"description"`
+	Instance    vault.Instance     `yaml:"instance"`
+	Options     map[string]string  `yaml:"options"`
+	Adopt       bool               `yaml:"adopt"`
+	Lifecycle   toplevel.Lifecycle `yaml:"lifecycle"`
 }
 
 const toplevelName = "vault_audit_backends"
@@ -38,6 +42,27 @@ func (e entry) KeyForDescription() string {
 	return e.Description
 }
 
+// Adopts returns whether the entry takes the ownership of an existing entry that is
+// not owned by vault-manager
+func (e entry) Adopts() bool {
+	return e.Adopt
+}
+
+// PreventsDestroy returns whether the entry is declared with `prevent_destroy`
+func (e entry) PreventsDestroy() bool {
+	return e.Lifecycle.PreventDestroy
+}
+
+// Retain returns the entry where the description and options ignored by its lifecycle
+// take the value of the existing audit device
+func (e entry) Retain(existing entry) entry {
+	if e.Lifecycle.Ignores("description") {
+		e.Description = existing.Description
+	}
+	e.Options = toplevel.Retain(e.Lifecycle, e.Options, existing.Options)
+	return e
+}
+
 func (e entry) Equals(i interface{}) bool {
 	entry, ok := i.(entry)
 	if !ok {
@@ -50,6 +75,25 @@ func (e entry) Equals(i interface{}) bool {
 		vault.OptionsEqual(e.ambiguousOptions(), entry.ambiguousOptions())
 }
 
+func (e entry) Diff(i interface{}) []string {
+	existing, ok := i.(entry)
+	if !ok {
+		return nil
+	}
+	diff := vault.DiffField("type", existing.Type, e.Type)
+	diff = append(diff, vault.DiffField("description", existing.Description, e.Description)...)
+	return append(diff, vault.DiffOptions(existing.ambiguousOptions(), e.ambiguousOptions())...)
+}
+
+func (e entry) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"path":        e.Path,
+		"type":        e.Type,
+		"description": e.Description,
+		"options":     e.Options,
+	}
+}
+
 func (e entry) ambiguousOptions() map[string]interface{} {
 	opts := make(map[string]interface{}, len(e.Options))
 	for k, v := range e.Options {
@@ -67,11 +111,12 @@ func init() {
 }
 
 // Apply ensures that an instance of Vault's Audit Devices are configured
-// exactly as provided.
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
+// exactly as provided. Devices are enabled in the write phase and disabled in
+// the delete phase.
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
 	var entries []entry
 	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
-		log.WithError(err).Fatal("[Vault Audit] failed to decode audit device configuration")
+		return vault.NewConfigError("[Vault Audit] failed to decode audit device configuration", err)
 	}
 	instancesToDesiredAudits := make(map[string][]entry)
 	for _, e := range entries {
@@ -82,11 +127,11 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		func(e entry) string {
 			return e.Key()
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
 	// perform reconcile operations for specific instance
-	enabledAudits, err := vault.ListAuditDevices(address)
+	enabledAudits, err := vault.ListAuditDevices(ctx, address)
 	if err != nil {
 		return err
 	}
@@ -102,45 +147,74 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		})
 	}
 
+	// audit devices that are not owned by vault-manager are left untouched
+	desiredAudits, existingAduits := toplevel.Owned(address, toplevelName, instancesToDesiredAudits[address], existingAduits)
+	desiredAudits = toplevel.IgnoreChanges(desiredAudits, existingAduits)
+
 	// Diff the local configuration with the Vault instance.
 	toBeWritten, toBeDeleted, _ :=
-		vault.DiffItems(asItems(instancesToDesiredAudits[address]), asItems(existingAduits))
+		vault.DiffItems(asItems(desiredAudits), asItems(existingAduits))
+	err = toplevel.CheckDeletions(address, toplevelName, len(toBeDeleted), len(existingAduits))
+	if err != nil {
+		return err
+	}
+	err = toplevel.CheckDestroys(address, toplevelName, desiredAudits, toBeDeleted)
+	if err != nil {
+		return err
+	}
 
-	if dryRun == true {
-		for _, w := range toBeWritten {
+	if phase == toplevel.PhaseWrite {
+		plan.AddItems(address, toplevelName, toplevel.ActionWrite, toBeWritten, asItems(existingAduits))
+		return enableAudits(ctx, address, toBeWritten, existingAduits, dryRun)
+	}
+	plan.AddItems(address, toplevelName, toplevel.ActionDelete, toBeDeleted, nil)
+	return disableAudits(ctx, address, toBeDeleted, dryRun)
+}
+
+// Write any missing Audit Devices to the Vault instance.
+func enableAudits(ctx context.Context, address string, toBeWritten []vault.Item, existing []entry, dryRun bool) error {
+	for _, w := range toBeWritten {
+		ent := w.(entry)
+		if dryRun == true {
 			log.WithFields(log.Fields{
 				"path":     w.Key(),
 				"instance": address,
 			}).Info("[Dry Run] [Vault Audit] audit device to be enabled")
+			for _, line := range vault.DiffItem(w, asItems(existing)) {
+				log.WithFields(log.Fields{
+					"path":     w.Key(),
+					"instance": address,
+				}).Infof("[Dry Run] [Vault Audit] audit device diff: %s", line)
+			}
+			continue
+		}
+		err := vault.EnableAuditDevice(ctx, address, ent.Path, &api.EnableAuditOptions{
+			Type:        ent.Type,
+			Description: ent.Description,
+			Options:     ent.Options,
+		})
+		if err != nil {
+			return err
 		}
-		for _, d := range toBeDeleted {
+	}
+	return nil
+}
+
+// Delete any Audit Devices from the Vault instance.
+func disableAudits(ctx context.Context, address string, toBeDeleted []vault.Item, dryRun bool) error {
+	for _, d := range toBeDeleted {
+		if dryRun == true {
 			log.WithFields(log.Fields{
 				"path":     d.Key(),
 				"instance": address,
 			}).Info("[Dry Run] [Vault Audit] audit device to be disabled")
+			continue
 		}
-	} else {
-		// Write any missing Audit Devices to the Vault instance.
-		for _, e := range toBeWritten {
-			ent := e.(entry)
-			err := vault.EnableAuditDevice(address, ent.Path, &api.EnableAuditOptions{
-				Type:        ent.Type,
-				Description: ent.Description,
-				Options:     ent.Options,
-			})
-			if err != nil {
-				return err
-			}
-		}
-		// Delete any Audit Devices from the Vault instance.
-		for _, e := range toBeDeleted {
-			err := vault.DisableAuditDevice(address, e.(entry).Path)
-			if err != nil {
-				return err
-			}
+		err := vault.DisableAuditDevice(ctx, address, d.(entry).Path)
+		if err != nil {
+			return err
 		}
 	}
-
 	return nil
 }
 
@@ -0,0 +1,33 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks required fields and the uniqueness of audit device paths per instance
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries []entry
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+
+	problems := []toplevel.Problem{}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		p := toplevel.Problem{Toplevel: toplevelName, Instance: e.Instance.Address, Entry: e.Path}
+		problems = append(problems, p.Require("_path", e.Path)...)
+		problems = append(problems, p.Require("type", e.Type)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		key := e.Instance.Address + strings.Trim(e.Path, "/")
+		if seen[key] {
+			problems = append(problems, p.Withf("duplicate audit device path"))
+		}
+		seen[key] = true
+	}
+	return problems
+}
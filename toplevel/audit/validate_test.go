@@ -0,0 +1,90 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		audits      string
+		expected    []string
+	}{
+		{
+			"valid audit device",
+			`
+- _path: file/
+  type: file
+  instance:
+    address: https://vault
+  options:
+    file_path: /var/log/vault/audit.log
+`,
+			[]string{},
+		},
+		{
+			"missing path",
+			`
+- type: file
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `_path`"},
+		},
+		{
+			"missing type",
+			`
+- _path: file/
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `type`"},
+		},
+		{
+			"duplicate path",
+			`
+- _path: file/
+  type: file
+  instance:
+    address: https://vault
+- _path: file
+  type: file
+  instance:
+    address: https://vault
+`,
+			[]string{"duplicate audit device path"},
+		},
+		{
+			"same path on different instances",
+			`
+- _path: file/
+  type: file
+  instance:
+    address: https://vault
+- _path: file/
+  type: file
+  instance:
+    address: https://other
+`,
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			problems := config{}.Validate([]byte(c.audits), toplevel.NewReferences())
+			messages := []string{}
+			for _, p := range problems {
+				messages = append(messages, p.Message)
+			}
+			require.Equal(t, c.expected, messages)
+		})
+	}
+}
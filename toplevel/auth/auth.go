@@ -3,6 +3,7 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -22,20 +23,26 @@ type entry struct {
 	Description    string                            `yaml:"description"`
 	Instance       vault.Instance                    `yaml:"instance"`
 	Settings       map[string]map[string]interface{} `yaml:"settings"`
+	Tune           map[string]interface{}            `yaml:"tune"`
 	PolicyMappings []policyMapping                   `yaml:"policy_mappings"`
+	Adopt          bool                              `yaml:"adopt"`
+	Lifecycle      toplevel.Lifecycle                `yaml:"lifecycle"`
 }
 
-type policyMapping struct {
-	Policies    []map[string]interface{} `yaml:"policies"`
-	Type        string                   `yaml:"type"`
-	Description string                   `yaml:"description"`
+// authTune represents the tunable attributes of an auth backend
+// read from and written to sys/auth/<path>/tune
+type authTune struct {
+	Path        string
+	Type        string
+	Description string
+	Options     map[string]interface{}
 }
 
 const toplevelName = "vault_auth_backends"
 
 var _ vault.Item = entry{}
 
-var _ vault.Item = policyMapping{}
+var _ vault.Item = authTune{}
 
 func (e entry) Key() string {
 	return e.Path
@@ -49,6 +56,17 @@ func (e entry) KeyForDescription() string {
 	return e.Description
 }
 
+// Adopts returns whether the entry takes the ownership of an existing entry that is
+// not owned by vault-manager
+func (e entry) Adopts() bool {
+	return e.Adopt
+}
+
+// PreventsDestroy returns whether the entry is declared with `prevent_destroy`
+func (e entry) PreventsDestroy() bool {
+	return e.Lifecycle.PreventDestroy
+}
+
 func (e entry) Equals(i interface{}) bool {
 	entry, ok := i.(entry)
 	if !ok {
@@ -59,38 +77,52 @@ func (e entry) Equals(i interface{}) bool {
 		e.Type == entry.Type
 }
 
-func (p policyMapping) KeyForType() string {
-	return p.Type
+func (e entry) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"path":        e.Path,
+		"type":        e.Type,
+		"description": e.Description,
+		"tune":        e.Tune,
+	}
+}
+
+func (t authTune) Key() string {
+	return t.Path
 }
 
-func (p policyMapping) Key() string {
-	// Implement a unique key for policyMapping, e.g., based on its type and description
-	return fmt.Sprintf("%s:%s", p.Type, p.Description)
+func (t authTune) KeyForType() string {
+	return t.Type
 }
 
-func (p policyMapping) KeyForDescription() string {
-	return p.Description
+func (t authTune) KeyForDescription() string {
+	return t.Description
 }
 
-func (p policyMapping) Equals(i interface{}) bool {
-	policyMapping, ok := i.(policyMapping)
+func (t authTune) Equals(i interface{}) bool {
+	tune, ok := i.(authTune)
 	if !ok {
 		return false
 	}
-	return comparePolicies(p.Policies, policyMapping.Policies)
+	return vault.EqualPathNames(t.Path, tune.Path) &&
+		t.Description == tune.Description &&
+		vault.OptionsEqual(t.Options, tune.Options)
 }
 
-func comparePolicies(xpolicies, ypolicies []map[string]interface{}) bool {
-	if len(xpolicies) != len(ypolicies) {
-		return false
+func (t authTune) Diff(i interface{}) []string {
+	existing, ok := i.(authTune)
+	if !ok {
+		return nil
 	}
+	diff := vault.DiffField("description", existing.Description, t.Description)
+	return append(diff, vault.DiffOptions(existing.Options, t.Options)...)
+}
 
-	for i, xp := range xpolicies {
-		if xp["name"].(string) != ypolicies[i]["name"].(string) {
-			return false
-		}
+func (t authTune) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"path":        t.Path,
+		"description": t.Description,
+		"tune":        t.Options,
 	}
-	return true
 }
 
 type config struct{}
@@ -102,12 +134,13 @@ func init() {
 }
 
 // Apply ensures that an instance of Vault's authentication backends are
-// configured exactly as provided.
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
+// configured exactly as provided. Backends are enabled, tuned and configured in
+// the write phase and disabled in the delete phase.
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
 	// Unmarshal the list of configured auth backends.
 	var entries []entry
 	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
-		log.WithError(err).Fatal("[Vault Auth] failed to decode auth backend configuration")
+		return vault.NewConfigError("[Vault Auth] failed to decode auth backend configuration", err)
 	}
 
 	// Organize by instance
@@ -116,16 +149,19 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		instancesToDesired[e.Instance.Address] = append(instancesToDesired[e.Instance.Address], e)
 	}
 	updateOptionalKubeDefaults(instancesToDesired[address])
+	for i := range instancesToDesired[address] {
+		instancesToDesired[address][i].Tune = vault.WithoutNilOptions(instancesToDesired[address][i].Tune)
+	}
 
 	if unique := utils.ValidKeys(instancesToDesired[address],
 		func(e entry) string {
 			return e.Key()
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
 	// Get the existing auth backends
-	existingAuthMounts, err := vault.ListAuthBackends(address)
+	existingAuthMounts, err := vault.ListAuthBackends(ctx, address)
 	if err != nil {
 		return err
 	}
@@ -143,23 +179,45 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		}
 	}
 
+	// auth backends that are not owned by vault-manager are left untouched
+	instancesToDesired[address], existingBackends = toplevel.Owned(address, toplevelName, instancesToDesired[address], existingBackends)
+
 	// Perform auth reconcile
 	toBeWritten, toBeDeleted, _ :=
 		vault.DiffItems(asItems(instancesToDesired[address]), asItems(existingBackends))
-	err = enableAuth(address, toBeWritten, dryRun)
+	err = toplevel.CheckDeletions(address, toplevelName, len(toBeDeleted), len(existingBackends))
 	if err != nil {
 		return err
 	}
-	err = configureAuthMounts(address, instancesToDesired[address], dryRun)
+	err = toplevel.CheckDestroys(address, toplevelName, instancesToDesired[address], toBeDeleted)
 	if err != nil {
 		return err
 	}
-	err = disableAuth(address, toBeDeleted, dryRun)
+	// roles of auth backends only planned to be enabled are planned during a dry run
+	plan.Overlay().RecordItems(toplevel.AuthBackendRef, address, toplevel.ActionWrite, toBeWritten)
+	plan.Overlay().RecordItems(toplevel.AuthBackendRef, address, toplevel.ActionDelete, toBeDeleted)
+	if phase == toplevel.PhaseDelete {
+		err = reconcileTeamMappings(ctx, phase, address, instancesToDesired[address], dryRun, plan)
+		if err != nil {
+			return err
+		}
+		return disableAuth(ctx, address, toBeDeleted, dryRun, plan)
+	}
+
+	plan.AddItems(address, toplevelName, toplevel.ActionWrite, toBeWritten, asItems(existingBackends))
+	err = enableAuth(ctx, address, toBeWritten, dryRun)
 	if err != nil {
 		return err
 	}
-
-	return nil
+	err = tuneAuth(ctx, address, instancesToDesired[address], existingAuthMounts, dryRun, plan)
+	if err != nil {
+		return err
+	}
+	err = configureAuthMounts(ctx, address, instancesToDesired[address], dryRun, plan)
+	if err != nil {
+		return err
+	}
+	return reconcileTeamMappings(ctx, phase, address, instancesToDesired[address], dryRun, plan)
 }
 
 // updateOptionalKubeDefaults maps omitted optional attributes from desired to default values in existing
@@ -184,10 +242,13 @@ func updateOptionalKubeDefaults(desired []entry) {
 	}
 }
 
-func enableAuth(instanceAddr string, toBeWritten []vault.Item, dryRun bool) error {
-	// TODO(riuvshin): implement auth tuning
+func enableAuth(ctx context.Context, instanceAddr string, toBeWritten []vault.Item, dryRun bool) error {
 	for _, e := range toBeWritten {
 		ent := e.(entry)
+		config, err := vault.MountConfigInput(ent.Tune)
+		if err != nil {
+			return fmt.Errorf("[Vault Auth] invalid tune for `%s`: %w", ent.Path, err)
+		}
 		if dryRun == true {
 			log.WithFields(log.Fields{
 				"path":     ent.Path,
@@ -195,10 +256,11 @@ func enableAuth(instanceAddr string, toBeWritten []vault.Item, dryRun bool) erro
 				"instance": instanceAddr,
 			}).Info("[Dry Run] [Vault Auth] auth backend to be enabled")
 		} else {
-			err := vault.EnableAuthWithOptions(instanceAddr, ent.Path,
+			err := vault.EnableAuthWithOptions(ctx, instanceAddr, ent.Path,
 				&api.EnableAuthOptions{
 					Type:        ent.Type,
 					Description: ent.Description,
+					Config:      config,
 				})
 			if err != nil {
 				return err
@@ -208,33 +270,95 @@ func enableAuth(instanceAddr string, toBeWritten []vault.Item, dryRun bool) erro
 	return nil
 }
 
-func configureAuthMounts(instanceAddr string, entries []entry, dryRun bool) error {
+// tuneAuth reconciles the description and tune attributes of already enabled auth backends
+// newly enabled backends receive their tune attributes when enabled
+func tuneAuth(ctx context.Context, instanceAddr string, entries []entry, existingAuthMounts map[string]*api.AuthMount,
+	dryRun bool, plan *toplevel.Plan) error {
+	for _, e := range entries {
+		var mount *api.AuthMount
+		for path, m := range existingAuthMounts {
+			if vault.EqualPathNames(path, e.Path) && m.Type == e.Type {
+				mount = m
+				break
+			}
+		}
+		if mount == nil {
+			continue
+		}
+		desired := authTune{Path: e.Path, Type: e.Type, Description: e.Description, Options: e.Tune}
+		existing := authTune{
+			Path:        e.Path,
+			Type:        mount.Type,
+			Description: mount.Description,
+			Options:     vault.TuneOptions(mount.Config, e.Tune),
+		}
+		// the description and tune attributes ignored by the lifecycle keep their existing value
+		if e.Lifecycle.Ignores("description") {
+			desired.Description = existing.Description
+		}
+		desired.Options = toplevel.Retain(e.Lifecycle, desired.Options, existing.Options)
+		if desired.Equals(existing) {
+			continue
+		}
+
+		plan.AddItems(instanceAddr, toplevelName, toplevel.ActionUpdate, []vault.Item{desired}, []vault.Item{existing})
+		if dryRun == true {
+			log.WithField("path", e.Path).WithField("type", e.Type).WithField("instance", instanceAddr).Info(
+				"[Dry Run] [Vault Auth] auth backend to be tuned")
+			for _, line := range desired.Diff(existing) {
+				log.WithField("path", e.Path).WithField("type", e.Type).WithField("instance", instanceAddr).Infof(
+					"[Dry Run] [Vault Auth] auth backend diff: %s", line)
+			}
+			continue
+		}
+		config, err := vault.MountConfigInput(desired.Options)
+		if err != nil {
+			return fmt.Errorf("[Vault Auth] invalid tune for `%s`: %w", e.Path, err)
+		}
+		config.Description = &desired.Description
+		err = vault.TuneAuth(ctx, instanceAddr, e.Path, config)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configureAuthMounts(ctx context.Context, instanceAddr string, entries []entry, dryRun bool, plan *toplevel.Plan) error {
 	// configure auth mounts
 	for _, e := range entries {
 		if e.Settings != nil {
 			if e.Type == "oidc" {
-				err := setOidcClientSecret(instanceAddr, e.Settings)
+				err := setOidcClientSecret(ctx, instanceAddr, e.Settings)
 				if err != nil {
 					return err
 				}
 			} else if e.Type == "kubernetes" {
-				err := setKubeCaCert(instanceAddr, e.Settings)
+				err := setKubeCaCert(ctx, instanceAddr, e.Settings)
 				if err != nil {
 					return err
 				}
 			}
 			for name, cfg := range e.Settings {
 				path := filepath.Join("auth", e.Path, name)
-				dataExists, err := vault.DataInSecret(instanceAddr, cfg, path, vault.KV_V1)
+				dataExists, err := vault.DataInSecret(ctx, instanceAddr, cfg, path, vault.KV_V1)
 				if err != nil {
 					return err
 				}
 				if !dataExists {
+					plan.Add(toplevel.Change{
+						Instance: instanceAddr,
+						Toplevel: toplevelName,
+						Key:      path,
+						Type:     e.Type,
+						Action:   toplevel.ActionWrite,
+						After:    redactSecrets(cfg),
+					})
 					if dryRun == true {
 						log.WithField("path", path).WithField("type", e.Type).WithField("instance", instanceAddr).Info(
 							"[Dry Run] [Vault Auth] auth backend configuration to be written")
 					} else {
-						err := vault.WriteSecret(instanceAddr, path, vault.KV_V1, cfg)
+						err := vault.WriteSecret(ctx, instanceAddr, path, vault.KV_V1, cfg)
 						if err != nil {
 							return err
 						}
@@ -248,17 +372,18 @@ func configureAuthMounts(instanceAddr string, entries []entry, dryRun bool) erro
 	return nil
 }
 
-func disableAuth(instanceAddr string, toBeDeleted []vault.Item, dryRun bool) error {
+func disableAuth(ctx context.Context, instanceAddr string, toBeDeleted []vault.Item, dryRun bool, plan *toplevel.Plan) error {
 	for _, e := range toBeDeleted {
 		ent := e.(entry)
 		if strings.HasPrefix(ent.Path, "token/") {
 			continue
 		}
+		plan.AddItems(instanceAddr, toplevelName, toplevel.ActionDelete, []vault.Item{ent}, nil)
 		if dryRun == true {
 			log.WithField("path", ent.Path).WithField("type", ent.Type).WithField("instance", instanceAddr).Info(
 				"[Dry Run] [Vault Auth] auth backend to be disabled")
 		} else {
-			err := vault.DisableAuth(instanceAddr, ent.Path)
+			err := vault.DisableAuth(ctx, instanceAddr, ent.Path)
 			if err != nil {
 				return err
 			}
@@ -277,16 +402,29 @@ func asItems(xs []entry) (items []vault.Item) {
 	return items
 }
 
+// returns a copy of an auth mount configuration with secret values masked
+// so that it can be safely recorded within a plan
+func redactSecrets(cfg map[string]interface{}) map[string]interface{} {
+	redacted := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		if k == vault.OIDC_CLIENT_SECRET {
+			v = "<redacted>"
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
 // retrieves client secret at vault location specified in oidc auth definition
 // and overwrites oidc_client_secret within desired object's settings
-func setOidcClientSecret(instanceAddr string, settings map[string]map[string]interface{}) error {
+func setOidcClientSecret(ctx context.Context, instanceAddr string, settings map[string]map[string]interface{}) error {
 	// logic to check existence of keys before referencing is unnecessary due to schema validation
 	cfg := settings["config"]
 	engineVersion := cfg[vault.OIDC_CLIENT_SECRET_KV_VER].(string)
 	location := cfg[vault.OIDC_CLIENT_SECRET].(map[interface{}]interface{})
 	path := location["path"].(string)
 	field := location["field"].(string)
-	secret, err := vault.GetVaultSecretField(instanceAddr, path, field, engineVersion)
+	secret, err := vault.GetVaultSecretField(ctx, instanceAddr, path, field, engineVersion)
 	if err != nil {
 		return errors.New(fmt.Sprintf(
 			"[Vault Auth] failed to retrieve `oidc_client_secret` for %s", instanceAddr))
@@ -298,7 +436,7 @@ func setOidcClientSecret(instanceAddr string, settings map[string]map[string]int
 
 // retrieves client secret from vault location specified in kubernetes auth definition
 // and overwrites kubernetes_ca_cert within desired object's settings
-func setKubeCaCert(instanceAddr string, settings map[string]map[string]interface{}) error {
+func setKubeCaCert(ctx context.Context, instanceAddr string, settings map[string]map[string]interface{}) error {
 	cfg := settings["config"]
 	// ca cert is optional within kube auth config
 	// if omitted from definition, proceeding assertion will fail
@@ -313,7 +451,7 @@ func setKubeCaCert(instanceAddr string, settings map[string]map[string]interface
 	}
 	path := location["path"].(string)
 	field := location["field"].(string)
-	cert, err := vault.GetVaultSecretField(instanceAddr, path, field, engineVersion)
+	cert, err := vault.GetVaultSecretField(ctx, instanceAddr, path, field, engineVersion)
 	if err != nil {
 		return errors.New(fmt.Sprintf(
 			"[Vault Auth] failed to retrieve `kubernetes_ca_cert` for %s", instanceAddr))
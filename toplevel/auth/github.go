@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	log "github.com/sirupsen/logrus"
+)
+
+// policyMapping maps the members of a github team to a set of vault policies
+// stored within auth/<github-mount>/map/teams/<team>
+type policyMapping struct {
+	GithubTeam  githubTeam               `yaml:"github_team"`
+	Policies    []map[string]interface{} `yaml:"policies"`
+	Type        string                   `yaml:"type"`
+	Description string                   `yaml:"description"`
+
+	// set from the github auth backend of the mapping
+	mount          string
+	adopt          bool
+	preventDestroy bool
+}
+
+type githubTeam struct {
+	Team string `yaml:"team"`
+}
+
+var _ vault.Item = policyMapping{}
+
+func (p policyMapping) KeyForType() string {
+	return p.Type
+}
+
+func (p policyMapping) Key() string {
+	return p.GithubTeam.Team
+}
+
+func (p policyMapping) KeyForDescription() string {
+	return p.Description
+}
+
+// OwnershipPath returns the path matched by ownership rules, `MOUNT/map/teams/TEAM`
+func (p policyMapping) OwnershipPath() string {
+	return path.Join(strings.Trim(p.mount, "/"), "map", "teams", p.Key())
+}
+
+// Adopts returns whether the github auth backend of the mapping is declared with `adopt`
+func (p policyMapping) Adopts() bool {
+	return p.adopt
+}
+
+// PreventsDestroy returns whether the github auth backend of the mapping is declared
+// with `prevent_destroy`
+func (p policyMapping) PreventsDestroy() bool {
+	return p.preventDestroy
+}
+
+func (p policyMapping) Equals(i interface{}) bool {
+	policyMapping, ok := i.(policyMapping)
+	if !ok {
+		return false
+	}
+	return p.Key() == policyMapping.Key() &&
+		comparePolicies(p.Policies, policyMapping.Policies)
+}
+
+func (p policyMapping) Diff(i interface{}) []string {
+	existing, ok := i.(policyMapping)
+	if !ok {
+		return nil
+	}
+	return vault.DiffField("policies", existing.policyNames(), p.policyNames())
+}
+
+func (p policyMapping) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"team":     p.Key(),
+		"policies": p.policyNames(),
+	}
+}
+
+// returns the sorted names of the policies referenced by the mapping
+func (p policyMapping) policyNames() []string {
+	names := []string{}
+	for _, policy := range p.Policies {
+		if name, ok := policy["name"].(string); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func comparePolicies(xpolicies, ypolicies []map[string]interface{}) bool {
+	x := policyMapping{Policies: xpolicies}.policyNames()
+	y := policyMapping{Policies: ypolicies}.policyNames()
+	return strings.Join(x, ",") == strings.Join(y, ",")
+}
+
+// teamMappingsDiff holds the team mappings to be written and deleted within a github auth backend
+type teamMappingsDiff struct {
+	mount       string
+	existing    []policyMapping
+	toBeWritten []vault.Item
+	toBeDeleted []vault.Item
+}
+
+// diffTeamMappings returns the changes of the team mappings of every desired github auth
+// backend. Like the backends, mappings that are not owned are left untouched, and their
+// deletions are checked against the deletion budget and `prevent_destroy`.
+func diffTeamMappings(ctx context.Context, instanceAddr string, entries []entry) ([]teamMappingsDiff, error) {
+	diffs := []teamMappingsDiff{}
+	for _, e := range entries {
+		if strings.ToLower(e.Type) != "github" {
+			continue
+		}
+		existing, err := getExistingTeamMappings(ctx, instanceAddr, e.Path)
+		if err != nil {
+			return nil, err
+		}
+		desired := lowerTeams(e.PolicyMappings)
+		for i := range desired {
+			desired[i].mount = e.Path
+			desired[i].adopt = e.Adopt
+			desired[i].preventDestroy = e.Lifecycle.PreventDestroy
+		}
+		desired, existing = toplevel.Owned(instanceAddr, toplevelName, desired, existing)
+
+		toBeWritten, toBeDeleted, _ := vault.DiffItems(mappingsAsItems(desired), mappingsAsItems(existing))
+		err = toplevel.CheckDeletions(instanceAddr, toplevelName, len(toBeDeleted), len(existing))
+		if err != nil {
+			return nil, err
+		}
+		// mappings are protected within the scope of their backend, as teams are
+		// mapped within every github auth backend
+		err = toplevel.CheckDestroys(instanceAddr, path.Join(toplevelName, strings.Trim(e.Path, "/")), desired, toBeDeleted)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, teamMappingsDiff{
+			mount:       e.Path,
+			existing:    existing,
+			toBeWritten: toBeWritten,
+			toBeDeleted: toBeDeleted,
+		})
+	}
+	return diffs, nil
+}
+
+// reconcileTeamMappings writes, in the write phase, or deletes, in the delete phase,
+// github team to policy mappings for every desired github auth backend
+func reconcileTeamMappings(ctx context.Context, phase toplevel.Phase, instanceAddr string, entries []entry, dryRun bool, plan *toplevel.Plan) error {
+	diffs, err := diffTeamMappings(ctx, instanceAddr, entries)
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		toBeWritten, toBeDeleted := diff.toBeWritten, diff.toBeDeleted
+		if phase == toplevel.PhaseWrite {
+			plan.AddItems(instanceAddr, toplevelName, toplevel.ActionWrite, toBeWritten, mappingsAsItems(diff.existing))
+			toBeDeleted = nil
+		} else {
+			plan.AddItems(instanceAddr, toplevelName, toplevel.ActionDelete, toBeDeleted, nil)
+			toBeWritten = nil
+		}
+
+		for _, w := range toBeWritten {
+			path := teamMappingPath(diff.mount, w.Key())
+			if dryRun == true {
+				log.WithField("path", path).WithField("instance", instanceAddr).Info(
+					"[Dry Run] [Vault Auth] github team policy mapping to be written")
+				for _, line := range vault.DiffItem(w, mappingsAsItems(diff.existing)) {
+					log.WithField("path", path).WithField("instance", instanceAddr).Infof(
+						"[Dry Run] [Vault Auth] github team policy mapping diff: %s", line)
+				}
+				continue
+			}
+			err := vault.WriteSecret(ctx, instanceAddr, path, vault.KV_V1, map[string]interface{}{
+				"value": strings.Join(w.(policyMapping).policyNames(), ","),
+			})
+			if err != nil {
+				return err
+			}
+			log.WithField("path", path).WithField("instance", instanceAddr).Info(
+				"[Vault Auth] github team policy mapping successfully written")
+		}
+
+		for _, d := range toBeDeleted {
+			path := teamMappingPath(diff.mount, d.Key())
+			if dryRun == true {
+				log.WithField("path", path).WithField("instance", instanceAddr).Info(
+					"[Dry Run] [Vault Auth] github team policy mapping to be deleted")
+				continue
+			}
+			err := vault.DeleteSecret(ctx, instanceAddr, path)
+			if err != nil {
+				return err
+			}
+			log.WithField("path", path).WithField("instance", instanceAddr).Info(
+				"[Vault Auth] github team policy mapping successfully deleted")
+		}
+	}
+	return nil
+}
+
+// returns the team mappings that currently exist within a github auth backend
+func getExistingTeamMappings(ctx context.Context, instanceAddr, mountPath string) ([]policyMapping, error) {
+	secret, err := vault.ListSecrets(ctx, instanceAddr, filepath.Join("auth", mountPath, "map", "teams"))
+	if err != nil {
+		return nil, err
+	}
+	existing := []policyMapping{}
+	if secret == nil {
+		return existing, nil
+	}
+	keys, ok := secret.Data["keys"].([]interface{})
+	if !ok {
+		return nil, &vault.APIError{
+			Instance: instanceAddr,
+			Op:       fmt.Sprintf("failed to list github team mappings of `%s`", mountPath),
+			Err:      fmt.Errorf("unexpected `keys` of type %T", secret.Data["keys"]),
+		}
+	}
+	for _, k := range keys {
+		team, ok := k.(string)
+		if !ok {
+			return nil, &vault.APIError{
+				Instance: instanceAddr,
+				Op:       fmt.Sprintf("failed to list github team mappings of `%s`", mountPath),
+				Err:      fmt.Errorf("unexpected key %v of type %T", k, k),
+			}
+		}
+		mapping, err := vault.ReadSecret(ctx, instanceAddr, teamMappingPath(mountPath, team), vault.KV_V1)
+		if err != nil {
+			return nil, err
+		}
+		policies := []map[string]interface{}{}
+		if value, ok := mapping["value"].(string); ok && value != "" {
+			for _, name := range strings.Split(value, ",") {
+				policies = append(policies, map[string]interface{}{"name": strings.TrimSpace(name)})
+			}
+		}
+		existing = append(existing, policyMapping{
+			GithubTeam: githubTeam{Team: strings.ToLower(team)},
+			Policies:   policies,
+			mount:      mountPath,
+		})
+	}
+	return existing, nil
+}
+
+// lowerTeams returns the mappings with lowercased team names, as vault lowercases the
+// names of the teams mapped within a github auth backend
+func lowerTeams(mappings []policyMapping) []policyMapping {
+	lowered := make([]policyMapping, 0, len(mappings))
+	for _, m := range mappings {
+		m.GithubTeam.Team = strings.ToLower(m.GithubTeam.Team)
+		lowered = append(lowered, m)
+	}
+	return lowered
+}
+
+func teamMappingPath(mountPath, team string) string {
+	return filepath.Join("auth", mountPath, "map", "teams", team)
+}
+
+func mappingsAsItems(xs []policyMapping) []vault.Item {
+	items := make([]vault.Item, 0)
+	for _, x := range xs {
+		items = append(items, x)
+	}
+	return items
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLowerTeams(t *testing.T) {
+	t.Parallel()
+
+	existing := []policyMapping{
+		{GithubTeam: githubTeam{Team: "sre-admins"}, Policies: []map[string]interface{}{{"name": "admin"}}},
+	}
+
+	cases := []struct {
+		description string
+		desired     policyMapping
+		written     int
+		deleted     int
+	}{
+		{
+			"same case",
+			policyMapping{GithubTeam: githubTeam{Team: "sre-admins"}, Policies: []map[string]interface{}{{"name": "admin"}}},
+			0, 0,
+		},
+		{
+			"mixed case",
+			policyMapping{GithubTeam: githubTeam{Team: "SRE-Admins"}, Policies: []map[string]interface{}{{"name": "admin"}}},
+			0, 0,
+		},
+		{
+			"mixed case with changed policies",
+			policyMapping{GithubTeam: githubTeam{Team: "SRE-Admins"}, Policies: []map[string]interface{}{{"name": "read"}}},
+			1, 0,
+		},
+		{
+			"other team",
+			policyMapping{GithubTeam: githubTeam{Team: "Devs"}, Policies: []map[string]interface{}{{"name": "admin"}}},
+			1, 1,
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			toBeWritten, toBeDeleted, _ := vault.DiffItems(
+				mappingsAsItems(lowerTeams([]policyMapping{c.desired})), mappingsAsItems(existing))
+			require.Len(t, toBeWritten, c.written)
+			require.Len(t, toBeDeleted, c.deleted)
+		})
+	}
+}
+
+func TestTeamMappingOwnershipPath(t *testing.T) {
+	t.Parallel()
+
+	m := policyMapping{GithubTeam: githubTeam{Team: "admins"}, mount: "github/"}
+	require.Equal(t, "github/map/teams/admins", m.OwnershipPath())
+}
+
+func TestTeamMappingDestroys(t *testing.T) {
+	t.Parallel()
+
+	scope := "vault_auth_backends/github"
+	admins := policyMapping{GithubTeam: githubTeam{Team: "admins"}, mount: "github/", preventDestroy: true}
+
+	// the mapping is recorded as protected by its backend
+	err := toplevel.CheckDestroys("https://github-vault", scope, []policyMapping{admins}, nil)
+	require.NoError(t, err)
+
+	// and may not be deleted once removed from the desired state
+	err = toplevel.CheckDestroys("https://github-vault", scope, []policyMapping{}, mappingsAsItems([]policyMapping{admins}))
+	require.EqualError(t, err,
+		"[https://github-vault] vault_auth_backends/github would delete entries declared with `prevent_destroy`: admins")
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks required fields, durations within tune and settings, the secret
+// references of oidc and kubernetes settings and the policies referenced by github
+// policy mappings. Every auth backend is declared so that references from other
+// configurations can be checked.
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries []entry
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+	refs.Manages(toplevel.AuthBackendRef)
+
+	problems := []toplevel.Problem{}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		p := toplevel.Problem{Toplevel: toplevelName, Instance: e.Instance.Address, Entry: e.Path}
+		problems = append(problems, p.Require("_path", e.Path)...)
+		problems = append(problems, p.Require("type", e.Type)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		key := e.Instance.Address + strings.Trim(e.Path, "/")
+		if seen[key] {
+			problems = append(problems, p.Withf("duplicate auth backend path"))
+		}
+		seen[key] = true
+		refs.Declare(toplevel.AuthBackendRef, e.Instance.Address, e.Path)
+
+		for _, msg := range toplevel.InvalidDurations(e.Tune) {
+			problems = append(problems, p.Withf("tune: %s", msg))
+		}
+		for _, name := range settingNames(e.Settings) {
+			for _, msg := range toplevel.InvalidDurations(e.Settings[name]) {
+				problems = append(problems, p.Withf("settings.%s: %s", name, msg))
+			}
+		}
+		switch e.Type {
+		case "oidc":
+			if e.Settings != nil {
+				problems = append(problems, validateSecretRef(p, e.Settings["config"],
+					vault.OIDC_CLIENT_SECRET, vault.OIDC_CLIENT_SECRET_KV_VER, true)...)
+			}
+		case "kubernetes":
+			if e.Settings != nil {
+				problems = append(problems, validateSecretRef(p, e.Settings["config"],
+					vault.KUBERNETES_CA_CERT, vault.KUBERNETES_CA_CERT_KV_VER, false)...)
+			}
+		}
+
+		for _, m := range e.PolicyMappings {
+			mp := p
+			mp.Entry = fmt.Sprintf("%s/%s", strings.Trim(e.Path, "/"), m.GithubTeam.Team)
+			problems = append(problems, mp.Require("github_team.team", m.GithubTeam.Team)...)
+			for _, policy := range m.Policies {
+				name, ok := policy["name"].(string)
+				if !ok || name == "" {
+					problems = append(problems, mp.Withf("policy without `name`"))
+					continue
+				}
+				refs.Reference(toplevel.PolicyRef, name, mp)
+			}
+		}
+	}
+	return problems
+}
+
+// validates the reference to a secret, a map containing `path` and `field`, within
+// the `config` settings of an auth backend and the kv version of the secret.
+// the kv version is mandatory when required is set and defaults to kv_v2 otherwise
+func validateSecretRef(p toplevel.Problem, cfg map[string]interface{}, key, kvVersionKey string, required bool) []toplevel.Problem {
+	if cfg[key] == nil {
+		if required {
+			return []toplevel.Problem{p.Withf("missing required setting `config.%s`", key)}
+		}
+		return nil
+	}
+	problems := []toplevel.Problem{}
+	location, ok := cfg[key].(map[interface{}]interface{})
+	if !ok {
+		return []toplevel.Problem{p.Withf("`config.%s` must reference a secret with `path` and `field`", key)}
+	}
+	for _, field := range []string{"path", "field"} {
+		if v, ok := location[field].(string); !ok || v == "" {
+			problems = append(problems, p.Withf("`config.%s` is missing `%s`", key, field))
+		}
+	}
+	kvVersion, ok := cfg[kvVersionKey].(string)
+	if cfg[kvVersionKey] == nil && !required {
+		return problems
+	}
+	if !ok || !toplevel.ValidKVVersion(kvVersion) {
+		problems = append(problems, p.Withf("`config.%s` must be one of `%s` or `%s`, got `%v`",
+			kvVersionKey, vault.KV_V1, vault.KV_V2, cfg[kvVersionKey]))
+	}
+	return problems
+}
+
+func settingNames(settings map[string]map[string]interface{}) []string {
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,236 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		backends    string
+		expected    []string
+	}{
+		{
+			"valid oidc backend",
+			`
+- _path: oidc/
+  type: oidc
+  instance:
+    address: https://vault
+  tune:
+    default_lease_ttl: 1h
+  settings:
+    config:
+      oidc_client_secret:
+        path: secret/oidc
+        field: client_secret
+      oidc_client_secret_kv_version: kv_v2
+`,
+			[]string{},
+		},
+		{
+			"missing type",
+			`
+- _path: approle/
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `type`"},
+		},
+		{
+			"duplicate path",
+			`
+- _path: approle/
+  type: approle
+  instance:
+    address: https://vault
+- _path: approle
+  type: approle
+  instance:
+    address: https://vault
+`,
+			[]string{"duplicate auth backend path"},
+		},
+		{
+			"tune with attributes unset by graphql",
+			`
+- _path: approle/
+  type: approle
+  instance:
+    address: https://vault
+  tune:
+    default_lease_ttl: 1h
+    max_lease_ttl: null
+    audit_non_hmac_request_keys: null
+    token_type: null
+`,
+			[]string{},
+		},
+		{
+			"empty ttl within tune",
+			`
+- _path: approle/
+  type: approle
+  instance:
+    address: https://vault
+  tune:
+    default_lease_ttl: ""
+`,
+			[]string{"tune: invalid duration `` of `default_lease_ttl`: empty duration"},
+		},
+		{
+			"invalid ttl within settings",
+			`
+- _path: kubernetes/
+  type: kubernetes
+  instance:
+    address: https://vault
+  settings:
+    config:
+      token_ttl: forever
+`,
+			[]string{"settings.config: invalid duration `forever` of `token_ttl`: time: invalid duration \"forever\""},
+		},
+		{
+			"missing oidc client secret",
+			`
+- _path: oidc/
+  type: oidc
+  instance:
+    address: https://vault
+  settings:
+    config:
+      oidc_discovery_url: https://sso
+`,
+			[]string{"missing required setting `config.oidc_client_secret`"},
+		},
+		{
+			"oidc client secret not a map",
+			`
+- _path: oidc/
+  type: oidc
+  instance:
+    address: https://vault
+  settings:
+    config:
+      oidc_client_secret: secret/oidc
+      oidc_client_secret_kv_version: kv_v2
+`,
+			[]string{"`config.oidc_client_secret` must reference a secret with `path` and `field`"},
+		},
+		{
+			"oidc client secret without field",
+			`
+- _path: oidc/
+  type: oidc
+  instance:
+    address: https://vault
+  settings:
+    config:
+      oidc_client_secret:
+        path: secret/oidc
+      oidc_client_secret_kv_version: kv_v2
+`,
+			[]string{"`config.oidc_client_secret` is missing `field`"},
+		},
+		{
+			"missing kv version of oidc client secret",
+			`
+- _path: oidc/
+  type: oidc
+  instance:
+    address: https://vault
+  settings:
+    config:
+      oidc_client_secret:
+        path: secret/oidc
+        field: client_secret
+`,
+			[]string{"`config.oidc_client_secret_kv_version` must be one of `kv_v1` or `kv_v2`, got `<nil>`"},
+		},
+		{
+			"invalid kv version of kubernetes ca cert",
+			`
+- _path: kubernetes/
+  type: kubernetes
+  instance:
+    address: https://vault
+  settings:
+    config:
+      kubernetes_ca_cert:
+        path: secret/kubernetes
+        field: ca
+      kubernetes_ca_cert_kv_version: kv_v3
+`,
+			[]string{"`config.kubernetes_ca_cert_kv_version` must be one of `kv_v1` or `kv_v2`, got `kv_v3`"},
+		},
+		{
+			"kubernetes ca cert defaults to kv_v2",
+			`
+- _path: kubernetes/
+  type: kubernetes
+  instance:
+    address: https://vault
+  settings:
+    config:
+      kubernetes_ca_cert:
+        path: secret/kubernetes
+        field: ca
+`,
+			[]string{},
+		},
+		{
+			"policy mapping without policy name",
+			`
+- _path: github/
+  type: github
+  instance:
+    address: https://vault
+  policy_mappings:
+  - github_team:
+      team: admins
+    policies:
+    - id: declared
+`,
+			[]string{"policy without `name`"},
+		},
+		{
+			"policy mapping referencing an undeclared policy",
+			`
+- _path: github/
+  type: github
+  instance:
+    address: https://vault
+  policy_mappings:
+  - github_team:
+      team: admins
+    policies:
+    - name: declared
+    - name: undeclared
+`,
+			[]string{"references policy `undeclared` which is not declared for the instance"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			refs := toplevel.NewReferences()
+			refs.Manages(toplevel.PolicyRef)
+			refs.Declare(toplevel.PolicyRef, "https://vault", "declared")
+
+			problems := config{}.Validate([]byte(c.backends), refs)
+			messages := []string{}
+			for _, p := range append(problems, refs.Dangling()...) {
+				messages = append(messages, p.Message)
+			}
+			require.Equal(t, c.expected, messages)
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package toplevel
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeletionBudgetError indicates that a top-level configuration would delete more entries
+// from an instance than allowed by the deletion budget, ex: when the desired state was
+// only partially fetched.
+type DeletionBudgetError struct {
+	Instance  string
+	Toplevel  string
+	Deletions int
+	Existing  int
+	// the exceeded budget as configured, ex: `10` or `25%`
+	Budget string
+}
+
+var _ vault.KindError = &DeletionBudgetError{}
+
+func (e *DeletionBudgetError) Error() string {
+	return fmt.Sprintf("[%s] %s would delete %d of %d existing entries, exceeding the deletion budget of %s",
+		e.Instance, e.Toplevel, e.Deletions, e.Existing, e.Budget)
+}
+
+// Kind classifies the error for vault.ErrorKind
+func (e *DeletionBudgetError) Kind() string {
+	return vault.BudgetErrorKind
+}
+
+// DeletionBudget limits the number of entries a top-level configuration deletes from
+// an instance within a single run, guarding against the deletion of every entry when
+// the desired state is only partially fetched.
+type DeletionBudget struct {
+	// limits by scope, see ParseDeletionBudget
+	limits map[string]budgetLimit
+	// when true, exceeded budgets are only logged
+	allowExceeded bool
+}
+
+type budgetLimit struct {
+	value   int
+	percent bool
+	// as configured, ex: `10` or `25%`
+	spec string
+}
+
+// exceeded returns whether deleting deletions of existing entries exceeds the limit
+func (l budgetLimit) exceeded(deletions, existing int) bool {
+	if l.percent {
+		return deletions*100 > existing*l.value
+	}
+	return deletions > l.value
+}
+
+// any top-level configuration or instance
+const budgetWildcard = "*"
+
+// ParseDeletionBudget parses a comma separated list of `SCOPE=LIMIT` rules. SCOPE is
+// `TOPLEVEL@INSTANCE`, where either side may be `*` for any, `TOPLEVEL` is short for
+// `TOPLEVEL@*`. LIMIT is a maximum number of deletions, ex: `10`, or a maximum
+// percentage of the existing entries, ex: `25%`. The most specific rule applies,
+// a configuration of an instance without rule is unlimited.
+// When allowExceeded is true, exceeded budgets are only logged.
+func ParseDeletionBudget(spec string, allowExceeded bool) (*DeletionBudget, error) {
+	b := &DeletionBudget{limits: make(map[string]budgetLimit), allowExceeded: allowExceeded}
+	if strings.TrimSpace(spec) == "" {
+		return b, nil
+	}
+
+	configsM.RLock()
+	defer configsM.RUnlock()
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		i := strings.LastIndex(rule, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid deletion budget %q, must be `SCOPE=LIMIT`", rule)
+		}
+		name, instance := rule[:i], budgetWildcard
+		if j := strings.Index(name, "@"); j >= 0 {
+			name, instance = name[:j], name[j+1:]
+		}
+		if name == "" || instance == "" {
+			return nil, fmt.Errorf("invalid scope of deletion budget %q", rule)
+		}
+		if _, ok := configs[name]; !ok && name != budgetWildcard {
+			return nil, fmt.Errorf("unknown top-level configuration `%s` of deletion budget %q", name, rule)
+		}
+
+		limit := budgetLimit{spec: rule[i+1:]}
+		value := strings.TrimSuffix(limit.spec, "%")
+		limit.percent = value != limit.spec
+		var err error
+		if limit.value, err = strconv.Atoi(value); err != nil || limit.value < 0 {
+			return nil, fmt.Errorf("invalid limit of deletion budget %q, must be a number or a percentage", rule)
+		}
+		key := budgetKey(name, instance)
+		if _, dup := b.limits[key]; dup {
+			return nil, fmt.Errorf("duplicate scope of deletion budget %q", rule)
+		}
+		b.limits[key] = limit
+	}
+	return b, nil
+}
+
+func budgetKey(toplevelName, instance string) string {
+	return toplevelName + "@" + instance
+}
+
+// limit returns the most specific limit of a configuration applied to an instance
+func (b *DeletionBudget) limit(instance, toplevelName string) (budgetLimit, bool) {
+	for _, key := range []string{
+		budgetKey(toplevelName, instance),
+		budgetKey(toplevelName, budgetWildcard),
+		budgetKey(budgetWildcard, instance),
+		budgetKey(budgetWildcard, budgetWildcard),
+	} {
+		if limit, ok := b.limits[key]; ok {
+			return limit, true
+		}
+	}
+	return budgetLimit{}, false
+}
+
+// Check returns a *DeletionBudgetError when deleting deletions of the existing
+// entries of a configuration exceeds its budget. A nil budget is unlimited.
+func (b *DeletionBudget) Check(instance, toplevelName string, deletions, existing int) error {
+	if b == nil || deletions == 0 {
+		return nil
+	}
+	limit, ok := b.limit(instance, toplevelName)
+	if !ok || !limit.exceeded(deletions, existing) {
+		return nil
+	}
+	err := &DeletionBudgetError{
+		Instance:  instance,
+		Toplevel:  toplevelName,
+		Deletions: deletions,
+		Existing:  existing,
+		Budget:    limit.spec,
+	}
+	if b.allowExceeded {
+		log.WithError(err).Warn("deletion budget exceeded, deletions are allowed by `-allow-mass-deletion`")
+		return nil
+	}
+	return err
+}
+
+var deletionBudget atomic.Pointer[DeletionBudget]
+
+// SetDeletionBudget sets the budget checked by CheckDeletions, unlimited when nil
+func SetDeletionBudget(b *DeletionBudget) {
+	deletionBudget.Store(b)
+}
+
+// CheckDeletions checks the deletions computed by a configuration against the budget
+// set by SetDeletionBudget. Configurations check their deletions in both phases,
+// before any change is made, so that a run exceeding the budget aborts during the
+// write phase and no entry is deleted.
+func CheckDeletions(instance, toplevelName string, deletions, existing int) error {
+	return deletionBudget.Load().Check(instance, toplevelName, deletions, existing)
+}
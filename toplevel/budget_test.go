@@ -0,0 +1,105 @@
+package toplevel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+type budgetedConfig struct{}
+
+func (budgetedConfig) Apply(context.Context, Phase, string, []byte, bool, int, *Plan) error {
+	return nil
+}
+
+func init() {
+	RegisterConfiguration("vault_budgeted", budgetedConfig{})
+}
+
+func TestParseDeletionBudget(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       string
+		expectedErr string
+	}{
+		{"empty", "", ""},
+		{"rules of every scope", "vault_budgeted=10, *@https://a=25%,vault_budgeted@https://a=0,*=50%", ""},
+		{"missing limit", "vault_budgeted", "invalid deletion budget \"vault_budgeted\", must be `SCOPE=LIMIT`"},
+		{"missing instance", "vault_budgeted@=1", "invalid scope of deletion budget \"vault_budgeted@=1\""},
+		{"unknown configuration", "vault_unknown=1",
+			"unknown top-level configuration `vault_unknown` of deletion budget \"vault_unknown=1\""},
+		{"invalid limit", "*=ten", "invalid limit of deletion budget \"*=ten\", must be a number or a percentage"},
+		{"negative limit", "*=-1%", "invalid limit of deletion budget \"*=-1%\", must be a number or a percentage"},
+		{"duplicate scope", "vault_budgeted=1,vault_budgeted@*=2", "duplicate scope of deletion budget \"vault_budgeted@*=2\""},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			_, err := ParseDeletionBudget(c.given, false)
+			if c.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expectedErr)
+			}
+		})
+	}
+}
+
+func TestDeletionBudgetCheck(t *testing.T) {
+	t.Parallel()
+
+	budget, err := ParseDeletionBudget("vault_budgeted=2,vault_budgeted@https://a=25%,*@https://b=0", false)
+	require.NoError(t, err)
+
+	cases := []struct {
+		description string
+		instance    string
+		toplevel    string
+		deletions   int
+		existing    int
+		exceeded    string
+	}{
+		{"within count", "https://c", "vault_budgeted", 2, 2, ""},
+		{"exceeded count", "https://c", "vault_budgeted", 3, 10, "2"},
+		{"instance rule is more specific", "https://a", "vault_budgeted", 3, 10, "25%"},
+		{"within percentage", "https://a", "vault_budgeted", 1, 4, ""},
+		{"exceeded percentage", "https://a", "vault_budgeted", 2, 4, "25%"},
+		{"any configuration of an instance", "https://b", "vault_policies", 1, 100, "0"},
+		{"without rule", "https://c", "vault_policies", 100, 100, ""},
+		{"without deletions", "https://b", "vault_policies", 0, 0, ""},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			err := budget.Check(c.instance, c.toplevel, c.deletions, c.existing)
+			if c.exceeded == "" {
+				require.NoError(t, err)
+				return
+			}
+			var budgetErr *DeletionBudgetError
+			require.ErrorAs(t, err, &budgetErr)
+			require.Equal(t, c.exceeded, budgetErr.Budget)
+			require.Equal(t, vault.BudgetErrorKind, vault.ErrorKind(err))
+		})
+	}
+}
+
+func TestDeletionBudgetAllowExceeded(t *testing.T) {
+	t.Parallel()
+
+	budget, err := ParseDeletionBudget("*=0", true)
+	require.NoError(t, err)
+	require.NoError(t, budget.Check("https://a", "vault_budgeted", 10, 10))
+
+	// unlimited without budget
+	var unlimited *DeletionBudget
+	require.NoError(t, unlimited.Check("https://a", "vault_budgeted", 10, 10))
+}
@@ -1,8 +1,10 @@
 package entity
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -18,14 +20,48 @@ type config struct{}
 
 const toplevelName = "vault_entities"
 
+// key of the entities declared with explicit aliases, reconciled by the `vault_entities` configuration
+const declaredKey = "vault_declared_entities"
+
+// comma separated list of auth types whose existing entities are managed by vault-manager.
+// existing entities with an alias of any other type are excluded from reconcile unless declared
+const managedAuthTypesEnv = "ENTITY_MANAGED_AUTH_TYPES"
+
+const defaultManagedAuthTypes = "oidc"
+
 var _ toplevel.Configuration = config{}
 
+// desiredState holds the users of `vault_entities`, whose roles derive oidc entities, and the
+// entities of `vault_declared_entities`
+type desiredState struct {
+	Users    []user           `yaml:"vault_entities"`
+	Declared []declaredEntity `yaml:"vault_declared_entities"`
+}
+
 type user struct {
 	Name        string `yaml:"name"`
 	OrgUsername string `yaml:"org_username"`
 	Roles       []role `yaml:"roles"`
 }
 
+// declaredEntity is an entity declared with explicit aliases, ex: kubernetes service accounts,
+// rather than derived from user roles
+type declaredEntity struct {
+	Name     string                `yaml:"name"`
+	Instance vault.Instance        `yaml:"instance"`
+	Metadata utils.JSONMap[string] `yaml:"metadata"`
+	Aliases  []aliasEntry          `yaml:"aliases"`
+	// takes the ownership of an existing entity that is not owned by vault-manager
+	Adopt     bool               `yaml:"adopt"`
+	Lifecycle toplevel.Lifecycle `yaml:"lifecycle"`
+}
+
+type aliasEntry struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path"`
+	Type string `yaml:"type"`
+}
+
 type role struct {
 	Name        string           `yaml:"name"`
 	Permissions []oidcPermission `yaml:"oidc_permissions"`
@@ -38,12 +74,16 @@ type oidcPermission struct {
 }
 
 type entity struct {
-	Name     string
-	Id       string
-	Type     string
-	Metadata interface{}
-	Aliases  []entityAlias
-	Instance vault.Instance
+	Name      string
+	Id        string
+	Type      string
+	Metadata  interface{}
+	Aliases   []entityAlias
+	Instance  vault.Instance
+	Adopt     bool
+	Lifecycle toplevel.Lifecycle
+	// declared with explicit aliases rather than derived from the oidc permissions of a user
+	Declared bool
 }
 
 type entityAlias struct {
@@ -51,6 +91,7 @@ type entityAlias struct {
 	Id         string
 	Type       string
 	AuthType   string
+	MountPath  string
 	AccessorId string
 	Instance   vault.Instance
 }
@@ -71,6 +112,42 @@ func (e entity) KeyForDescription() string {
 	return fmt.Sprintf("%v", e.Metadata)
 }
 
+// Adopts returns whether the entity takes the ownership of an existing entity that is
+// not owned by vault-manager
+func (e entity) Adopts() bool {
+	return e.Adopt
+}
+
+// ManagedBy returns the managed-by metadata of the entity
+func (e entity) ManagedBy() string {
+	metadata, _ := e.Metadata.(map[string]interface{})
+	managedBy, _ := metadata[toplevel.ManagedByKey].(string)
+	return managedBy
+}
+
+// PreventsDestroy returns whether the entity is declared with `prevent_destroy`
+func (e entity) PreventsDestroy() bool {
+	return e.Lifecycle.PreventDestroy
+}
+
+// Retain returns the entity where the metadata takes the value of the existing entity
+// when ignored by its lifecycle
+func (e entity) Retain(existing entity) entity {
+	if !e.Lifecycle.Ignores("metadata") {
+		return e
+	}
+	e.Metadata = existing.Metadata
+	// the managed-by metadata is added to the metadata of desired entities
+	if metadata, ok := existing.Metadata.(map[string]interface{}); ok {
+		copied := make(map[string]interface{}, len(metadata))
+		for k, v := range metadata {
+			copied[k] = v
+		}
+		e.Metadata = copied
+	}
+	return e
+}
+
 func (e entity) Equals(i interface{}) bool {
 	entry, ok := i.(entity)
 	if !ok {
@@ -80,12 +157,19 @@ func (e entity) Equals(i interface{}) bool {
 		reflect.DeepEqual(e.Metadata, entry.Metadata)
 }
 
-func (e entity) CreateOrUpdate(action string) error {
+func (e entity) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"name":     e.Name,
+		"metadata": e.Metadata,
+	}
+}
+
+func (e entity) CreateOrUpdate(ctx context.Context, action string) error {
 	path := filepath.Join("identity", e.Type, "name", e.Name)
 	config := map[string]interface{}{
 		"metadata": e.Metadata,
 	}
-	err := vault.WriteSecret(e.Instance.Address, path, vault.KV_V1, config)
+	err := vault.WriteSecret(ctx, e.Instance.Address, path, vault.KV_V1, config)
 	if err != nil {
 		return err
 	}
@@ -97,9 +181,9 @@ func (e entity) CreateOrUpdate(action string) error {
 	return nil
 }
 
-func (e entity) Delete() error {
+func (e entity) Delete(ctx context.Context) error {
 	path := filepath.Join("identity", e.Type, "name", e.Name)
-	err := vault.DeleteSecret(e.Instance.Address, path)
+	err := vault.DeleteSecret(ctx, e.Instance.Address, path)
 	if err != nil {
 		return err
 	}
@@ -111,8 +195,9 @@ func (e entity) Delete() error {
 	return nil
 }
 
+// aliases are unique per mount, an entity may have aliases of the same name on different mounts
 func (e entityAlias) Key() string {
-	return e.Name
+	return filepath.Join(e.MountPath, e.Name)
 }
 
 func (e entityAlias) KeyForType() string {
@@ -129,17 +214,26 @@ func (e entityAlias) Equals(i interface{}) bool {
 		return false
 	}
 	return e.Name == entry.Name &&
-		e.AuthType == entry.AuthType
+		e.AuthType == entry.AuthType &&
+		e.MountPath == entry.MountPath
 }
 
-func (ea entityAlias) Create(entityId string) error {
+func (ea entityAlias) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       ea.Name,
+		"auth_type":  ea.AuthType,
+		"mount_path": ea.MountPath,
+	}
+}
+
+func (ea entityAlias) Create(ctx context.Context, entityId string) error {
 	path := filepath.Join("identity", ea.Type)
 	config := map[string]interface{}{
 		"name":           ea.Name,
 		"canonical_id":   entityId,
 		"mount_accessor": ea.AccessorId,
 	}
-	err := vault.WriteEntityAlias(ea.Instance.Address, path, config)
+	err := vault.WriteEntityAlias(ctx, ea.Instance.Address, path, config)
 	if err != nil {
 		return err
 	}
@@ -151,14 +245,14 @@ func (ea entityAlias) Create(entityId string) error {
 	return nil
 }
 
-func (ea entityAlias) Update(entityId string) error {
+func (ea entityAlias) Update(ctx context.Context, entityId string) error {
 	path := filepath.Join("identity", ea.Type, "id", ea.Id)
 	config := map[string]interface{}{
 		"name":           ea.Name,
 		"canonical_id":   entityId,
 		"mount_accessor": ea.AccessorId,
 	}
-	err := vault.WriteSecret(ea.Instance.Address, path, vault.KV_V1, config)
+	err := vault.WriteSecret(ctx, ea.Instance.Address, path, vault.KV_V1, config)
 	if err != nil {
 		return err
 	}
@@ -170,9 +264,9 @@ func (ea entityAlias) Update(entityId string) error {
 	return nil
 }
 
-func (ea entityAlias) Delete() error {
+func (ea entityAlias) Delete(ctx context.Context) error {
 	path := filepath.Join("identity", ea.Type, "id", ea.Id)
-	err := vault.DeleteSecret(ea.Instance.Address, path)
+	err := vault.DeleteSecret(ctx, ea.Instance.Address, path)
 	if err != nil {
 		return err
 	}
@@ -188,27 +282,35 @@ func init() {
 	toplevel.RegisterConfiguration(toplevelName, config{})
 }
 
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
+// Includes returns the key of declared entities, reconciled alongside the entities derived
+// from user roles so that neither are deleted as not desired by the other
+func (c config) Includes() []string {
+	return []string{declaredKey}
+}
+
+// Apply ensures that an instance of Vault's entities and their aliases are configured
+// exactly as provided. Entities and aliases are written and updated in the write phase
+// and deleted in the delete phase, except for aliases moved to another entity that are
+// deleted right before being written again.
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
 	// process desired entities/aliases
-	var entries []user
+	var entries desiredState
 	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
-		log.WithError(err).Fatal("[Vault Identity] failed to decode entity configuration")
+		return vault.NewConfigError("[Vault Identity] failed to decode entity configuration", err)
 	}
 
-	desired := getDesired(address, entries)
+	desired := getDesired(address, entries.Users, entries.Declared)
 	populateAliasType(desired)
 
 	if unique := utils.ValidKeys(desired,
 		func(e entity) string {
 			return e.Key()
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
-	desiredItems := asItems(desired)
-
 	// Process data on existing entities/aliases
-	existingEntities, err := createBaseExistingEntities(address)
+	existingEntities, err := createBaseExistingEntities(ctx, address)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": address,
@@ -216,10 +318,14 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		return err
 	}
 
-	pruneNonOidcEntities(&existingEntities)
+	managed := os.Getenv(managedAuthTypesEnv)
+	if managed == "" {
+		managed = defaultManagedAuthTypes
+	}
+	pruneUnmanagedEntities(&existingEntities, desired, managedAuthTypes(managed))
 
 	if existingEntities != nil && len(existingEntities) > 0 {
-		err := getExistingEntitiesDetails(address, existingEntities, threadPoolSize)
+		err := getExistingEntitiesDetails(ctx, address, existingEntities, threadPoolSize)
 		if err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"instance": address,
@@ -230,49 +336,90 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		copyIds(desired, existingEntities)
 	}
 
+	// entities that are not owned by vault-manager are left untouched
+	desired, existingEntities = toplevel.Owned(address, toplevelName, desired, existingEntities)
+	desired = toplevel.IgnoreChanges(desired, existingEntities)
+	if toplevel.MarksOwnership(toplevelName) {
+		markOwnership(desired)
+	}
+	desiredItems := asItems(desired)
+
 	// determine entity changes
 	entitiesToBeWritten, entitiesToBeDeleted, entitiesToBeUpdated :=
 		vault.DiffItems(desiredItems, asItems(existingEntities))
+	err = toplevel.CheckDeletions(address, toplevelName, len(entitiesToBeDeleted), len(existingEntities))
+	if err != nil {
+		return err
+	}
+	err = toplevel.CheckDestroys(address, toplevelName, desired, entitiesToBeDeleted)
+	if err != nil {
+		return err
+	}
 	// determine entity alias changes
 	aliasesToBeWritten, aliasesToBeDeleted, aliasesToBeUpdated :=
 		determineAliasActions(desired, existingEntities, entitiesToBeDeleted)
 
+	movedAliases, aliasesToBeDeleted := separateMovedAliases(aliasesToBeWritten, aliasesToBeDeleted)
+	// groups include entities only planned to be written during a dry run
+	plan.Overlay().RecordItems(toplevel.EntityRef, address, toplevel.ActionWrite, entitiesToBeWritten)
+
+	if phase == toplevel.PhaseDelete {
+		plan.AddItems(address, toplevelName, toplevel.ActionDelete, entitiesToBeDeleted, nil)
+		plan.AddItems(address, toplevelName, toplevel.ActionDelete, aliasesToBeDeleted, nil)
+		if dryRun {
+			entitiesDryRunOutput(address, entitiesToBeDeleted, "deleted")
+			aliasesDeletedDryRunOutput(address, aliasesToBeDeleted)
+			return nil
+		}
+		// aliases are deleted first, as deleting an entity deletes its aliases
+		err := deleteAliases(ctx, aliasesToBeDeleted)
+		if err != nil {
+			return err
+		}
+		for _, d := range entitiesToBeDeleted {
+			err := d.(entity).Delete(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	plan.AddItems(address, toplevelName, toplevel.ActionWrite, entitiesToBeWritten, asItems(existingEntities))
+	plan.AddItems(address, toplevelName, toplevel.ActionUpdate, entitiesToBeUpdated, asItems(existingEntities))
+	plan.AddItems(address, toplevelName, toplevel.ActionDelete, movedAliases, nil)
+	for _, aliases := range aliasesToBeWritten {
+		for _, ws := range aliases {
+			plan.AddItems(address, toplevelName, toplevel.ActionWrite, ws, nil)
+		}
+	}
+	for _, us := range aliasesToBeUpdated {
+		plan.AddItems(address, toplevelName, toplevel.ActionUpdate, us, nil)
+	}
+
 	// preform actions
 	if dryRun {
 		entitiesDryRunOutput(address, entitiesToBeWritten, "written")
-		entitiesDryRunOutput(address, entitiesToBeDeleted, "deleted")
 		entitiesDryRunOutput(address, entitiesToBeUpdated, "updated")
+		aliasesDeletedDryRunOutput(address, movedAliases)
 		aliasesDryRunOutput(address, aliasesToBeWritten["id"], "written")
 		aliasesDryRunOutput(address, aliasesToBeWritten["name"], "written")
-		for _, alias := range aliasesToBeDeleted {
-			log.WithFields(log.Fields{
-				"name":     alias.Key(),
-				"type":     alias.(entityAlias).AuthType,
-				"instance": address,
-			}).Info("[Dry Run] [Vault Identity] entity alias to be deleted")
-		}
 		aliasesDryRunOutput(address, aliasesToBeUpdated, "updated")
 	} else {
 		// TODO: make each action perform concurrently
 		for _, w := range entitiesToBeWritten {
-			err := w.(entity).CreateOrUpdate("written")
-			if err != nil {
-				return err
-			}
-		}
-		for _, d := range entitiesToBeDeleted {
-			err := d.(entity).Delete()
+			err := w.(entity).CreateOrUpdate(ctx, "written")
 			if err != nil {
 				return err
 			}
 		}
 		for _, u := range entitiesToBeUpdated {
-			err := u.(entity).CreateOrUpdate("update")
+			err := u.(entity).CreateOrUpdate(ctx, "update")
 			if err != nil {
 				return err
 			}
 		}
-		err = performAliasReconcile(address, aliasesToBeWritten, aliasesToBeDeleted, aliasesToBeUpdated)
+		err = performAliasReconcile(ctx, address, aliasesToBeWritten, movedAliases, aliasesToBeUpdated)
 		if err != nil {
 			log.WithError(err).WithFields(log.Fields{
 				"instance": address,
@@ -284,9 +431,44 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 	return nil
 }
 
-// getDesired accepts the yaml-marshalled result of the `vault_entities` graphql
-// query and returns entity/entity-alias object slice of desired for particular instance address
-func getDesired(address string, entries []user) []entity {
+// separateMovedAliases separates the aliases to be deleted that are written again for
+// another entity, an alias being unique per mount
+func separateMovedAliases(aliasesToBeWritten map[string]map[string][]vault.Item,
+	aliasesToBeDeleted []vault.Item) (moved []vault.Item, deleted []vault.Item) {
+	written := make(map[string]bool)
+	for _, aliases := range aliasesToBeWritten {
+		for _, ws := range aliases {
+			for _, w := range ws {
+				written[w.Key()] = true
+			}
+		}
+	}
+	moved = make([]vault.Item, 0)
+	deleted = make([]vault.Item, 0, len(aliasesToBeDeleted))
+	for _, d := range aliasesToBeDeleted {
+		if written[d.Key()] {
+			moved = append(moved, d)
+		} else {
+			deleted = append(deleted, d)
+		}
+	}
+	return moved, deleted
+}
+
+func aliasesDeletedDryRunOutput(address string, aliases []vault.Item) {
+	for _, alias := range aliases {
+		log.WithFields(log.Fields{
+			"name":     alias.(entityAlias).Name,
+			"mount":    alias.(entityAlias).MountPath,
+			"type":     alias.(entityAlias).AuthType,
+			"instance": address,
+		}).Info("[Dry Run] [Vault Identity] entity alias to be deleted")
+	}
+}
+
+// getDesired accepts the yaml-marshalled result of the `vault_entities` and `vault_declared_entities`
+// graphql queries and returns entity/entity-alias object slice of desired for particular instance address
+func getDesired(address string, entries []user, declared []declaredEntity) []entity {
 	desired := []entity{}
 	// need to track org name
 	// a user file can ref multi roles but user should only be appended once
@@ -304,10 +486,11 @@ func getDesired(address string, entries []user) []entity {
 						Type: "entity",
 						Aliases: []entityAlias{
 							{
-								Name:     u.OrgUsername,
-								Type:     "entity-alias",
-								AuthType: "oidc",
-								Instance: p.Instance,
+								Name:      u.OrgUsername,
+								Type:      "entity-alias",
+								AuthType:  "oidc",
+								MountPath: "oidc",
+								Instance:  p.Instance,
 							},
 						},
 						Metadata: map[string]interface{}{
@@ -322,12 +505,50 @@ func getDesired(address string, entries []user) []entity {
 			}
 		}
 	}
+	// entities declared with explicit aliases, ex: kubernetes or approle mounts
+	for _, e := range declared {
+		if e.Instance.Address == address {
+			desired = append(desired, getDeclared(e))
+		}
+	}
 	return desired
 }
 
+// getDeclared returns the desired entity of an entry that explicitly lists its aliases
+func getDeclared(u declaredEntity) entity {
+	metadata := make(map[string]interface{}, len(u.Metadata))
+	for k, v := range u.Metadata {
+		metadata[k] = v
+	}
+	declared := entity{
+		Name:      u.Name,
+		Type:      "entity",
+		Metadata:  metadata,
+		Instance:  u.Instance,
+		Adopt:     u.Adopt,
+		Lifecycle: u.Lifecycle,
+		Declared:  true,
+	}
+	for _, a := range u.Aliases {
+		declared.Aliases = append(declared.Aliases, entityAlias{
+			Name:      a.Name,
+			Type:      "entity-alias",
+			AuthType:  a.Type,
+			MountPath: normalizeMountPath(a.Path),
+			Instance:  u.Instance,
+		})
+	}
+	return declared
+}
+
+// returns the path of an auth mount without `auth/` prefix and surrounding slashes
+func normalizeMountPath(path string) string {
+	return strings.Trim(strings.TrimPrefix(strings.Trim(path, "/"), "auth/"), "/")
+}
+
 // processes all relevant info for entities/entity aliases from single vault api request
-func createBaseExistingEntities(instanceAddr string) ([]entity, error) {
-	raw, err := vault.ListEntities(instanceAddr)
+func createBaseExistingEntities(ctx context.Context, instanceAddr string) ([]entity, error) {
+	raw, err := vault.ListEntities(ctx, instanceAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -404,11 +625,14 @@ func createBaseExistingEntities(instanceAddr string) ([]entity, error) {
 				mountType = vals["mount_type"].(string)
 			}
 
+			mountPath, _ := vals["mount_path"].(string)
+
 			processedAliases = append(processedAliases, entityAlias{
-				Id:       aliasId,
-				Name:     aliasName,
-				AuthType: mountType,
-				Instance: vault.Instance{Address: instanceAddr},
+				Id:        aliasId,
+				Name:      aliasName,
+				AuthType:  mountType,
+				MountPath: normalizeMountPath(mountPath),
+				Instance:  vault.Instance{Address: instanceAddr},
 			})
 		}
 
@@ -425,9 +649,10 @@ func createBaseExistingEntities(instanceAddr string) ([]entity, error) {
 
 // performs concurrent requests to retrieve additional details for existing entities/entity aliases
 // these details require explicit requests to vault api for each entitiy/alias
-func getExistingEntitiesDetails(instanceAddr string, entities []entity, threadPoolSize int) error {
+func getExistingEntitiesDetails(ctx context.Context, instanceAddr string, entities []entity, threadPoolSize int) error {
 	bwg := utils.NewBoundedWaitGroup(threadPoolSize)
-	ch := make(chan error)
+	// buffered so that goroutines do not block until every goroutine finished
+	ch := make(chan error, len(entities))
 
 	for i := 0; i < len(entities); i++ {
 		bwg.Add(1)
@@ -435,7 +660,7 @@ func getExistingEntitiesDetails(instanceAddr string, entities []entity, threadPo
 		go func(e *entity, ch chan<- error) {
 			defer bwg.Done()
 
-			info, err := vault.GetEntityInfo(instanceAddr, e.Name)
+			info, err := vault.GetEntityInfo(ctx, instanceAddr, e.Name)
 			if err != nil {
 				ch <- err
 				return
@@ -457,7 +682,7 @@ func getExistingEntitiesDetails(instanceAddr string, entities []entity, threadPo
 
 			// TODO: make this a nested goroutine
 			for j := 0; j < len(e.Aliases); j++ {
-				rawAlias, err := vault.GetEntityAliasInfo(instanceAddr, e.Aliases[j].Id)
+				rawAlias, err := vault.GetEntityAliasInfo(ctx, instanceAddr, e.Aliases[j].Id)
 				if err != nil {
 					ch <- err
 					return
@@ -484,12 +709,10 @@ func getExistingEntitiesDetails(instanceAddr string, entities []entity, threadPo
 	}
 
 	// do not close channel until all goroutines finish
-	func() {
-		bwg.Wait()
-		close(ch)
-	}()
+	bwg.Wait()
+	close(ch)
 
-	// wait indefinitely for error responses until close() is called
+	// only the first error is reported
 	for e := range ch {
 		if e != nil {
 			return e
@@ -498,21 +721,40 @@ func getExistingEntitiesDetails(instanceAddr string, entities []entity, threadPo
 	return nil
 }
 
+// parses the comma separated list of managed auth types
+func managedAuthTypes(raw string) map[string]bool {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(raw, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+	return types
+}
+
 // removes existing entities that have been flagged as being connected to approles, github, etc
-// from inclusion in reconcile process
-func pruneNonOidcEntities(entities *[]entity) {
-	var isOidc bool
+// from inclusion in reconcile process. an existing entity is kept when all of its aliases are
+// of a managed auth type or when an entity of the same name is declared with explicit aliases.
+// entities of oidc users with aliases of other types, ex: added out-of-band, are left untouched
+func pruneUnmanagedEntities(entities *[]entity, desired []entity, managed map[string]bool) {
+	declared := make(map[string]bool)
+	for _, d := range desired {
+		if d.Declared {
+			declared[d.Name] = true
+		}
+	}
+	var isManaged bool
 	i := 0
 	for _, e := range *entities {
-		isOidc = true
-		// ignore entire entity if a single alias is not oidc type
+		isManaged = true
+		// ignore entire entity if a single alias is not of a managed type
 		for _, a := range e.Aliases {
-			if a.AuthType != "oidc" {
-				isOidc = false
+			if !managed[a.AuthType] {
+				isManaged = false
 				break
 			}
 		}
-		if isOidc {
+		if isManaged || declared[e.Name] {
 			(*entities)[i] = e
 			i++
 		}
@@ -566,14 +808,14 @@ func determineAliasActions(entries, existingEntities []entity, entitiesToBeDelet
 	return aliasesToBeWritten, aliasesToBeDeleted, aliasesToBeUpdated
 }
 
-// writes, deletes, and/or updates entity aliases
-func performAliasReconcile(instanceAddr string, aliasesToBeWritten map[string]map[string][]vault.Item,
-	aliasesToBeDeleted []vault.Item, aliasesToBeUpdated map[string][]vault.Item) error {
+// deletes moved aliases, then writes and/or updates entity aliases
+func performAliasReconcile(ctx context.Context, instanceAddr string, aliasesToBeWritten map[string]map[string][]vault.Item,
+	movedAliases []vault.Item, aliasesToBeUpdated map[string][]vault.Item) error {
 	var accessorIds map[string]string
 	// extra work (vault api request) required to organize accessor ids
-	if len(aliasesToBeWritten) > 0 {
+	if len(aliasesToBeWritten) > 0 || len(aliasesToBeUpdated) > 0 {
 		accessorIds = make(map[string]string)
-		authBackends, err := vault.ListAuthBackends(instanceAddr)
+		authBackends, err := vault.ListAuthBackends(ctx, instanceAddr)
 		if err != nil {
 			return err
 		}
@@ -581,12 +823,17 @@ func performAliasReconcile(instanceAddr string, aliasesToBeWritten map[string]ma
 			accessorIds[strings.TrimRight(k, "/")] = v.Accessor
 		}
 	}
+	// aliases moved to another entity are deleted before being written again
+	err := deleteAliases(ctx, movedAliases)
+	if err != nil {
+		return err
+	}
 	if _, exists := aliasesToBeWritten["id"]; exists {
 		for id, ws := range aliasesToBeWritten["id"] {
 			for _, w := range ws {
 				a := w.(entityAlias)
-				a.AccessorId = accessorIds[a.AuthType]
-				err := a.Create(id)
+				a.AccessorId = accessorIds[a.MountPath]
+				err := a.Create(ctx, id)
 				if err != nil {
 					return err
 				}
@@ -600,8 +847,8 @@ func performAliasReconcile(instanceAddr string, aliasesToBeWritten map[string]ma
 		for name, ws := range aliasesToBeWritten["name"] {
 			for _, w := range ws {
 				a := w.(entityAlias)
-				a.AccessorId = accessorIds[a.AuthType]
-				newEntity, err := vault.GetEntityInfo(instanceAddr, name)
+				a.AccessorId = accessorIds[a.MountPath]
+				newEntity, err := vault.GetEntityInfo(ctx, instanceAddr, name)
 				if err != nil {
 					return err
 				}
@@ -609,21 +856,48 @@ func performAliasReconcile(instanceAddr string, aliasesToBeWritten map[string]ma
 					return errors.New(fmt.Sprintf(
 						"[Vault Identity] failed to get info for newly created entity: %s", name))
 				}
-				a.Create(newEntity["id"].(string))
+				err = a.Create(ctx, newEntity["id"].(string))
+				if err != nil {
+					return err
+				}
 			}
 		}
 	}
-	for _, d := range aliasesToBeDeleted {
-		d.(entityAlias).Delete()
-	}
 	for id, us := range aliasesToBeUpdated {
 		for _, u := range us {
-			u.(entityAlias).Update(id)
+			a := u.(entityAlias)
+			a.AccessorId = accessorIds[a.MountPath]
+			err := a.Update(ctx, id)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func deleteAliases(ctx context.Context, aliases []vault.Item) error {
+	for _, d := range aliases {
+		if err := d.(entityAlias).Delete(ctx); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// markOwnership adds the managed-by metadata to desired entities, so that they are owned
+// by vault-manager once written
+func markOwnership(entries []entity) {
+	for i := range entries {
+		metadata, ok := entries[i].Metadata.(map[string]interface{})
+		if !ok {
+			metadata = make(map[string]interface{})
+			entries[i].Metadata = metadata
+		}
+		metadata[toplevel.ManagedByKey] = toplevel.ManagedByValue
+	}
+}
+
 // due to yaml unmarshal limitation, nested objects are initially unmarshalled as json strings
 // unmarshallMetadatas targets nested object attributes defined in entity schema and properly unmarshalls
 func unmarshallMetadatas(entries []entity) error {
@@ -672,7 +946,7 @@ func copyIds(entries, existingEntities []entity) {
 		existingEntityIds[entity.Name] = entity.Id
 		existingEntityAliasIds[entity.Name] = make(map[string]string)
 		for _, alias := range entity.Aliases {
-			existingEntityAliasIds[entity.Name][alias.Name] = alias.Id
+			existingEntityAliasIds[entity.Name][alias.Key()] = alias.Id
 		}
 	}
 	// update entity ids
@@ -685,8 +959,8 @@ func copyIds(entries, existingEntities []entity) {
 	for _, entity := range entries {
 		if _, exists := existingEntityAliasIds[entity.Name]; exists {
 			for i := 0; i < len(entity.Aliases); i++ {
-				if _, exists := existingEntityAliasIds[entity.Name][entity.Aliases[i].Name]; exists {
-					entity.Aliases[i].Id = existingEntityAliasIds[entity.Name][entity.Aliases[i].Name]
+				if _, exists := existingEntityAliasIds[entity.Name][entity.Aliases[i].Key()]; exists {
+					entity.Aliases[i].Id = existingEntityAliasIds[entity.Name][entity.Aliases[i].Key()]
 				}
 			}
 		}
@@ -709,7 +983,8 @@ func aliasesDryRunOutput(instanceAddr string, idsToAliases map[string][]vault.It
 	for _, aliases := range idsToAliases {
 		for _, alias := range aliases {
 			log.WithFields(log.Fields{
-				"name":     alias.Key(),
+				"name":     alias.(entityAlias).Name,
+				"mount":    alias.(entityAlias).MountPath,
 				"type":     alias.(entityAlias).AuthType,
 				"instance": instanceAddr,
 			}).Infof("[Dry Run] [Vault Identity] entity alias to be %s", action)
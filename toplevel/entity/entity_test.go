@@ -0,0 +1,79 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestPruneUnmanagedEntities(t *testing.T) {
+	t.Parallel()
+
+	existing := []entity{
+		{Name: "oidc-user", Aliases: []entityAlias{{Name: "oidc-user", AuthType: "oidc"}}},
+		// oidc user with an alias added out-of-band
+		{Name: "oidc-user-with-approle", Aliases: []entityAlias{
+			{Name: "oidc-user-with-approle", AuthType: "oidc"},
+			{Name: "ci", AuthType: "approle"},
+		}},
+		{Name: "service-account", Aliases: []entityAlias{{Name: "sa", AuthType: "kubernetes"}}},
+		{Name: "unknown", Aliases: []entityAlias{{Name: "unknown", AuthType: "approle"}}},
+	}
+	desired := getDesired("https://vault", []user{
+		{
+			OrgUsername: "oidc-user-with-approle",
+			Roles: []role{{Permissions: []oidcPermission{
+				{Service: "vault", Instance: vault.Instance{Address: "https://vault"}},
+			}}},
+		},
+	}, []declaredEntity{
+		{
+			Name:     "service-account",
+			Instance: vault.Instance{Address: "https://vault"},
+			Aliases:  []aliasEntry{{Name: "sa", Path: "kubernetes", Type: "kubernetes"}},
+		},
+	})
+
+	pruneUnmanagedEntities(&existing, desired, managedAuthTypes(defaultManagedAuthTypes))
+	names := []string{}
+	for _, e := range existing {
+		names = append(names, e.Name)
+	}
+	require.Equal(t, []string{"oidc-user", "service-account"}, names)
+}
+
+func TestGetDesiredDeclared(t *testing.T) {
+	t.Parallel()
+
+	var entries desiredState
+	err := yaml.Unmarshal([]byte(`
+vault_declared_entities:
+- name: sa
+  instance:
+    address: https://vault
+  metadata: '{"team": "sre"}'
+  aliases:
+  - name: sa
+    path: auth/kubernetes/
+    type: kubernetes
+  adopt: true
+- name: other
+  instance:
+    address: https://other
+  aliases:
+  - name: other
+    path: approle
+    type: approle
+`), &entries)
+	require.NoError(t, err)
+
+	desired := getDesired("https://vault", entries.Users, entries.Declared)
+	require.Len(t, desired, 1)
+	require.Equal(t, "sa", desired[0].Name)
+	require.True(t, desired[0].Adopts())
+	require.Equal(t, map[string]interface{}{"team": "sre"}, desired[0].Metadata)
+	require.Equal(t, "kubernetes", desired[0].Aliases[0].MountPath)
+	require.Equal(t, "kubernetes", desired[0].Aliases[0].AuthType)
+}
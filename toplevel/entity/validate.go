@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"fmt"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks the users of `vault_entities` and the required fields of declared entities.
+// The auth backend of each alias is referenced so that it can be checked against
+// declared auth backends.
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries desiredState
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+
+	problems := []toplevel.Problem{}
+	for _, u := range entries.Users {
+		// ex: an entity declared within `vault_entities` rather than `vault_declared_entities`
+		problems = append(problems, toplevel.Problem{Toplevel: toplevelName, Entry: u.Name}.Require("org_username", u.OrgUsername)...)
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range entries.Declared {
+		p := toplevel.Problem{Toplevel: declaredKey, Instance: e.Instance.Address, Entry: e.Name}
+		problems = append(problems, p.Require("name", e.Name)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		if seen[e.Instance.Address+e.Name] {
+			problems = append(problems, p.Withf("duplicate entity name"))
+		}
+		seen[e.Instance.Address+e.Name] = true
+
+		if len(e.Aliases) == 0 {
+			problems = append(problems, p.Withf("missing required field `aliases`"))
+		}
+		for i, a := range e.Aliases {
+			problems = append(problems, p.Require(fmt.Sprintf("aliases[%d].name", i), a.Name)...)
+			problems = append(problems, p.Require(fmt.Sprintf("aliases[%d].path", i), a.Path)...)
+			problems = append(problems, p.Require(fmt.Sprintf("aliases[%d].type", i), a.Type)...)
+			if a.Path != "" {
+				refs.Reference(toplevel.AuthBackendRef, normalizeMountPath(a.Path), p)
+			}
+		}
+	}
+	return problems
+}
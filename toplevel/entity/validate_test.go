@@ -0,0 +1,139 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		entities    string
+		expected    []string
+	}{
+		{
+			"valid entity",
+			`
+vault_declared_entities:
+- name: ci
+  instance:
+    address: https://vault
+  metadata: '{"team": "sre"}'
+  aliases:
+  - name: ci
+    path: approle/
+    type: approle
+`,
+			[]string{},
+		},
+		{
+			"valid oidc user",
+			`
+vault_entities:
+- name: User
+  org_username: user
+  roles: []
+`,
+			[]string{},
+		},
+		{
+			"declared entity within vault_entities",
+			`
+vault_entities:
+- name: ci
+  instance:
+    address: https://vault
+  aliases:
+  - name: ci
+    path: approle/
+    type: approle
+`,
+			[]string{"missing required field `org_username`"},
+		},
+		{
+			"missing aliases",
+			`
+vault_declared_entities:
+- name: ci
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `aliases`"},
+		},
+		{
+			"missing alias name and type",
+			`
+vault_declared_entities:
+- name: ci
+  instance:
+    address: https://vault
+  aliases:
+  - path: approle/
+`,
+			[]string{"missing required field `aliases[0].name`", "missing required field `aliases[0].type`"},
+		},
+		{
+			"duplicate name",
+			`
+vault_declared_entities:
+- name: ci
+  instance:
+    address: https://vault
+  aliases:
+  - name: ci
+    path: approle/
+    type: approle
+- name: ci
+  instance:
+    address: https://vault
+  aliases:
+  - name: deploy
+    path: approle/
+    type: approle
+`,
+			[]string{"duplicate entity name"},
+		},
+		{
+			"alias referencing an undeclared auth backend",
+			`
+vault_declared_entities:
+- name: sa
+  instance:
+    address: https://vault
+  aliases:
+  - name: sa
+    path: auth/kubernetes/
+    type: kubernetes
+`,
+			[]string{"references auth backend `kubernetes` which is not declared for the instance"},
+		},
+		{
+			"entities as a list",
+			`
+- name: ci
+`,
+			[]string{"failed to decode configuration: yaml: unmarshal errors:\n  line 2: cannot unmarshal !!seq into entity.desiredState"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			refs := toplevel.NewReferences()
+			refs.Manages(toplevel.AuthBackendRef)
+			refs.Declare(toplevel.AuthBackendRef, "https://vault", "approle/")
+
+			problems := config{}.Validate([]byte(c.entities), refs)
+			messages := []string{}
+			for _, p := range append(problems, refs.Dangling()...) {
+				messages = append(messages, p.Message)
+			}
+			require.Equal(t, c.expected, messages)
+		})
+	}
+}
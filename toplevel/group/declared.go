@@ -0,0 +1,395 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/utils"
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	internalGroupType = "internal"
+	externalGroupType = "external"
+	// auth mount used for group aliases when none is specified
+	defaultAliasMount = "oidc"
+)
+
+// declaredGroup is a group declared within `vault_declared_groups` rather than derived from user roles.
+// the membership of external groups is managed by an auth backend, ex: the `groups_claim` of
+// an oidc backend, while internal groups may include other groups
+type declaredGroup struct {
+	Name         string                `yaml:"name"`
+	Type         string                `yaml:"type"`
+	Instance     vault.Instance        `yaml:"instance"`
+	Metadata     utils.JSONMap[string] `yaml:"metadata"`
+	Policies     []vaultPolicy         `yaml:"vault_policies"`
+	MemberGroups []memberGroup         `yaml:"member_groups"`
+	GroupsClaim  string                `yaml:"groups_claim"`
+	Mount        string                `yaml:"mount"`
+	// takes the ownership of an existing group that is not owned by vault-manager
+	Adopt     bool               `yaml:"adopt"`
+	Lifecycle toplevel.Lifecycle `yaml:"lifecycle"`
+}
+
+type memberGroup struct {
+	Name string `yaml:"name"`
+}
+
+// groupAlias binds an external group to a group name provided by an auth backend
+type groupAlias struct {
+	Name        string
+	Id          string
+	GroupName   string
+	CanonicalId string
+	MountPath   string
+	AccessorId  string
+	Instance    vault.Instance
+}
+
+var _ vault.Item = groupAlias{}
+
+// an external group can only have a single alias
+func (a groupAlias) Key() string {
+	return a.GroupName
+}
+
+func (a groupAlias) KeyForType() string {
+	return "group-alias"
+}
+
+func (a groupAlias) KeyForDescription() string {
+	return a.MountPath
+}
+
+func (a groupAlias) Equals(i interface{}) bool {
+	alias, ok := i.(groupAlias)
+	if !ok {
+		return false
+	}
+	return a.GroupName == alias.GroupName &&
+		a.Name == alias.Name &&
+		a.MountPath == alias.MountPath
+}
+
+func (a groupAlias) Diff(i interface{}) []string {
+	existing, ok := i.(groupAlias)
+	if !ok {
+		return nil
+	}
+	diff := vault.DiffField("name", existing.Name, a.Name)
+	return append(diff, vault.DiffField("mount_path", existing.MountPath, a.MountPath)...)
+}
+
+func (a groupAlias) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"name":       a.Name,
+		"group":      a.GroupName,
+		"mount_path": a.MountPath,
+	}
+}
+
+// creates the alias or, when an alias already exists for the group, updates it
+func (a groupAlias) CreateOrUpdate(ctx context.Context) error {
+	config := map[string]interface{}{
+		"name":           a.Name,
+		"canonical_id":   a.CanonicalId,
+		"mount_accessor": a.AccessorId,
+	}
+	path := filepath.Join("identity", "group-alias")
+	if a.Id != "" {
+		path = filepath.Join(path, "id", a.Id)
+	}
+	err := vault.WriteGroupAlias(ctx, a.Instance.Address, path, config)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"instance": a.Instance.Address,
+		"path":     filepath.Join("identity", "group-alias", a.Name),
+		"group":    a.GroupName,
+		"mount":    a.MountPath,
+	}).Info("[Vault Identity] group alias successfully written")
+	return nil
+}
+
+func (a groupAlias) Delete(ctx context.Context) error {
+	path := filepath.Join("identity", "group-alias", "id", a.Id)
+	err := vault.DeleteSecret(ctx, a.Instance.Address, path)
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"instance": a.Instance.Address,
+		"path":     filepath.Join("identity", "group-alias", a.Name),
+		"group":    a.GroupName,
+		"mount":    a.MountPath,
+	}).Info("[Vault Identity] group alias successfully deleted")
+	return nil
+}
+
+// processDeclared returns the groups and group aliases declared for an instance
+func processDeclared(instanceAddr string, entries []declaredGroup) ([]group, []groupAlias) {
+	groups := []group{}
+	aliases := []groupAlias{}
+	for _, e := range entries {
+		if (e.Type != internalGroupType && e.Type != externalGroupType) || e.Instance.Address != instanceAddr {
+			continue
+		}
+		policies := []string{}
+		for _, policy := range e.Policies {
+			policies = append(policies, policy.Name)
+		}
+		memberGroups := []string{}
+		for _, m := range e.MemberGroups {
+			memberGroups = append(memberGroups, m.Name)
+		}
+		metadata := make(map[string]interface{}, len(e.Metadata))
+		for k, v := range e.Metadata {
+			metadata[k] = v
+		}
+		groups = append(groups, group{
+			Name:         e.Name,
+			Type:         "group",
+			GroupType:    e.Type,
+			Instance:     e.Instance,
+			Metadata:     metadata,
+			Policies:     policies,
+			EntityIds:    []string{},
+			MemberGroups: memberGroups,
+			// groups declared without `member_groups` keep their existing member groups
+			ManagesMemberGroups: e.MemberGroups != nil,
+			Adopt:               e.Adopt,
+			Lifecycle:           e.Lifecycle,
+		})
+		if e.Type != externalGroupType || e.GroupsClaim == "" {
+			continue
+		}
+		mount := e.Mount
+		if mount == "" {
+			mount = defaultAliasMount
+		}
+		aliases = append(aliases, groupAlias{
+			Name:      e.GroupsClaim,
+			GroupName: e.Name,
+			MountPath: strings.Trim(strings.TrimPrefix(strings.Trim(mount, "/"), "auth/"), "/"),
+			Instance:  e.Instance,
+		})
+	}
+	return groups, aliases
+}
+
+// returns list of existing group aliases. the name of the group each alias
+// belongs to is resolved from the ids of the existing groups
+func getExistingGroupAliases(ctx context.Context, instanceAddr string, existingGroups []group) ([]groupAlias, error) {
+	raw, err := vault.ListGroupAliases(ctx, instanceAddr)
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+	if _, exists := raw["key_info"]; !exists {
+		return nil, errors.New(
+			"Required `key_info` attribute not found in response from vault.ListGroupAliases()")
+	}
+	existingAliases, ok := raw["key_info"].(map[string]interface{})
+	if !ok {
+		return nil, errors.New(fmt.Sprintf(
+			"Failed to convert `key_info` to map[string]interface{}"))
+	}
+
+	groupIdsToNames := make(map[string]string)
+	for _, g := range existingGroups {
+		groupIdsToNames[g.Id] = g.Name
+	}
+
+	processed := []groupAlias{}
+	for id, v := range existingAliases {
+		values, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New(fmt.Sprintf(
+				"Failed to convert value to map[string]interface{} for group alias id: %s", id))
+		}
+		name, ok := values["name"].(string)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf(
+				"Required `name` attribute not found for group alias id: %s", id))
+		}
+		canonicalId, ok := values["canonical_id"].(string)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf(
+				"Required `canonical_id` attribute not found for group alias id: %s", id))
+		}
+		mountPath, _ := values["mount_path"].(string)
+		accessor, _ := values["mount_accessor"].(string)
+		processed = append(processed, groupAlias{
+			Name:        name,
+			Id:          id,
+			GroupName:   groupIdsToNames[canonicalId],
+			CanonicalId: canonicalId,
+			MountPath:   strings.Trim(strings.TrimPrefix(strings.Trim(mountPath, "/"), "auth/"), "/"),
+			AccessorId:  accessor,
+			Instance:    vault.Instance{Address: instanceAddr},
+		})
+	}
+	// stable order for output
+	sort.Slice(processed, func(i, j int) bool {
+		return processed[i].Key() < processed[j].Key()
+	})
+	return processed, nil
+}
+
+// determines and performs the changes required for group aliases of external groups.
+// must be called after groups are written as new groups do not have an id beforehand
+func reconcileGroupAliases(ctx context.Context, phase toplevel.Phase, instanceAddr string, desired []groupAlias,
+	existing []groupAlias, groupsToBeDeleted []vault.Item, dryRun bool, plan *toplevel.Plan) error {
+	// aliases of deleted groups are removed by vault alongside the group
+	deletedGroups := make(map[string]bool)
+	for _, g := range groupsToBeDeleted {
+		deletedGroups[g.Key()] = true
+	}
+	managed := []groupAlias{}
+	for _, e := range existing {
+		if e.GroupName != "" && !deletedGroups[e.GroupName] {
+			managed = append(managed, e)
+		}
+	}
+
+	toBeWritten, toBeDeleted, _ := vault.DiffItems(aliasesAsItems(desired), aliasesAsItems(managed))
+	if phase == toplevel.PhaseDelete {
+		plan.AddItems(instanceAddr, toplevelName, toplevel.ActionDelete, toBeDeleted, nil)
+		if dryRun {
+			aliasesDryRunOutput(instanceAddr, toBeDeleted, nil, "deleted")
+			return nil
+		}
+		return deleteGroupAliases(ctx, toBeDeleted)
+	}
+
+	// an alias is unique per mount, aliases moved to another group are deleted before being written again
+	moved := movedAliases(toBeWritten, existing)
+	plan.AddItems(instanceAddr, toplevelName, toplevel.ActionDelete, moved, nil)
+	plan.AddItems(instanceAddr, toplevelName, toplevel.ActionWrite, toBeWritten, aliasesAsItems(managed))
+
+	if dryRun {
+		aliasesDryRunOutput(instanceAddr, moved, nil, "deleted")
+		aliasesDryRunOutput(instanceAddr, toBeWritten, aliasesAsItems(managed), "written")
+		return nil
+	}
+	if len(toBeWritten) == 0 {
+		return nil
+	}
+	if err := deleteGroupAliases(ctx, moved); err != nil {
+		return err
+	}
+
+	existingIds := make(map[string]string)
+	for _, e := range managed {
+		existingIds[e.GroupName] = e.Id
+	}
+	accessorIds := make(map[string]string)
+	authBackends, err := vault.ListAuthBackends(ctx, instanceAddr)
+	if err != nil {
+		return err
+	}
+	for k, v := range authBackends {
+		accessorIds[strings.TrimRight(k, "/")] = v.Accessor
+	}
+
+	for _, w := range toBeWritten {
+		a := w.(groupAlias)
+		accessor, exists := accessorIds[a.MountPath]
+		if !exists {
+			return fmt.Errorf("[Vault Identity] auth mount `%s` of group alias `%s` does not exist", a.MountPath, a.Name)
+		}
+		info, err := vault.GetGroupInfo(ctx, instanceAddr, a.GroupName)
+		if err != nil {
+			return err
+		}
+		if info == nil {
+			return errors.New(fmt.Sprintf(
+				"[Vault Identity] failed to get info for group: %s", a.GroupName))
+		}
+		a.AccessorId = accessor
+		a.CanonicalId = info["id"].(string)
+		a.Id = existingIds[a.GroupName]
+		if err := a.CreateOrUpdate(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// movedAliases returns the existing aliases sharing the mount and name of an alias
+// to be written for another group. aliases of groups that are not owned are not moved
+func movedAliases(toBeWritten []vault.Item, existing []groupAlias) []vault.Item {
+	moved := []vault.Item{}
+	for _, e := range existing {
+		if e.GroupName == "" {
+			continue
+		}
+		for _, w := range toBeWritten {
+			a := w.(groupAlias)
+			if a.GroupName != e.GroupName && a.MountPath == e.MountPath && a.Name == e.Name {
+				moved = append(moved, e)
+				break
+			}
+		}
+	}
+	return moved
+}
+
+func deleteGroupAliases(ctx context.Context, aliases []vault.Item) error {
+	for _, d := range aliases {
+		if err := d.(groupAlias).Delete(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withOwnedGroups returns the aliases of the desired groups that are owned
+func withOwnedGroups(aliases []groupAlias, owned []group) []groupAlias {
+	names := make(map[string]bool, len(owned))
+	for _, g := range owned {
+		names[g.Name] = true
+	}
+	filtered := []groupAlias{}
+	for _, a := range aliases {
+		if names[a.GroupName] {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
+
+func aliasesAsItems(aliases []groupAlias) []vault.Item {
+	items := []vault.Item{}
+	for _, alias := range aliases {
+		items = append(items, alias)
+	}
+	return items
+}
+
+// reusable func to output writes and deletes for group aliases
+// differences from existing aliases of the same group are included
+func aliasesDryRunOutput(instanceAddr string, aliases, existing []vault.Item, action string) {
+	for _, a := range aliases {
+		alias := a.(groupAlias)
+		log.WithFields(log.Fields{
+			"name":     alias.Name,
+			"group":    alias.GroupName,
+			"mount":    alias.MountPath,
+			"instance": instanceAddr,
+		}).Infof("[Dry Run] [Vault Identity] group alias to be %s", action)
+		for _, line := range vault.DiffItem(a, existing) {
+			log.Infof("[Dry Run] [Vault Identity] group alias='%v' diff: %s", alias.GroupName, line)
+		}
+	}
+}
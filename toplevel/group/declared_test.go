@@ -0,0 +1,99 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMovedAliases(t *testing.T) {
+	t.Parallel()
+
+	existing := []groupAlias{
+		{Name: "sre", GroupName: "sre-old", MountPath: "oidc"},
+		{Name: "sre", GroupName: "sre-github", MountPath: "github"},
+		{Name: "dev", GroupName: "dev", MountPath: "oidc"},
+	}
+	toBeWritten := []vault.Item{
+		// renamed group keeping its alias
+		groupAlias{Name: "sre", GroupName: "sre", MountPath: "oidc"},
+		// alias of an existing group renamed
+		groupAlias{Name: "developers", GroupName: "dev", MountPath: "oidc"},
+	}
+
+	moved := movedAliases(toBeWritten, existing)
+	require.Equal(t, []vault.Item{existing[0]}, moved)
+}
+
+func TestRetainIgnoredChanges(t *testing.T) {
+	t.Parallel()
+
+	lifecycle := toplevel.Lifecycle{IgnoreChanges: []string{"metadata", "vault_policies"}}
+	desired := group{
+		Name:                "sre",
+		Metadata:            map[string]interface{}{"team": "sre"},
+		Policies:            []string{"sre"},
+		MemberGroups:        []string{"dev"},
+		ManagesMemberGroups: true,
+		Lifecycle:           lifecycle,
+	}
+
+	retained := desired.Retain(group{Name: "sre", Policies: []string{"sre", "break-glass"}, MemberGroups: []string{}})
+	require.Nil(t, retained.Metadata)
+	require.Equal(t, []string{"sre", "break-glass"}, retained.Policies)
+	require.Equal(t, []string{"dev"}, retained.MemberGroups)
+
+	// marking the desired group leaves the metadata of the existing group unchanged
+	existing := group{Name: "sre", Metadata: map[string]interface{}{"team": "platform"}}
+	retained = desired.Retain(existing)
+	markOwnership([]group{retained})
+	require.Equal(t, map[string]interface{}{"team": "platform"}, existing.Metadata)
+	require.Equal(t, toplevel.ManagedByValue, retained.ManagedBy())
+}
+
+func TestRetainUnmanagedMemberGroups(t *testing.T) {
+	t.Parallel()
+
+	existing := group{Name: "sre", MemberGroups: []string{"oncall"}}
+
+	derived := group{Name: "sre", MemberGroups: []string{}}
+	require.Equal(t, []string{"oncall"}, derived.Retain(existing).MemberGroups)
+
+	declared := group{Name: "sre", MemberGroups: []string{}, ManagesMemberGroups: true}
+	require.Equal(t, []string{}, declared.Retain(existing).MemberGroups)
+}
+
+func TestProcessDeclaredMemberGroups(t *testing.T) {
+	t.Parallel()
+
+	// graphql returns null member groups for groups declared without them
+	var entries desiredState
+	err := yaml.Unmarshal([]byte(`
+vault_declared_groups:
+- name: sre
+  type: internal
+  instance:
+    address: https://vault
+  member_groups: null
+- name: sre-all
+  type: internal
+  instance:
+    address: https://vault
+  member_groups:
+  - name: sre
+- name: sre-none
+  type: internal
+  instance:
+    address: https://vault
+  member_groups: []
+`), &entries)
+	require.NoError(t, err)
+
+	groups, _ := processDeclared("https://vault", entries.Declared)
+	require.False(t, groups[0].ManagesMemberGroups)
+	require.True(t, groups[1].ManagesMemberGroups)
+	require.True(t, groups[2].ManagesMemberGroups)
+}
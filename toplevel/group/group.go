@@ -1,6 +1,7 @@
 package group
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"path/filepath"
@@ -19,8 +20,21 @@ type config struct{}
 
 const toplevelName = "vault_groups"
 
+// top-level key of the groups declared rather than derived from user roles,
+// reconciled alongside the groups of `vault_groups`
+const declaredKey = "vault_declared_groups"
+
 var _ toplevel.Configuration = config{}
 
+var _ toplevel.Includer = config{}
+
+// desiredState holds the users of `vault_groups`, whose roles derive groups, and the
+// groups of `vault_declared_groups`
+type desiredState struct {
+	Users    []user          `yaml:"vault_groups"`
+	Declared []declaredGroup `yaml:"vault_declared_groups"`
+}
+
 type user struct {
 	Name  string `yaml:"org_username"`
 	Roles []role `yaml:"roles"`
@@ -44,14 +58,26 @@ type vaultPolicy struct {
 }
 
 type group struct {
-	Name      string
-	Id        string
-	Type      string
-	Instance  vault.Instance
-	Metadata  map[string]interface{}
-	Policies  []string
-	EntityIds []string
-	Usernames []string
+	Name           string
+	Id             string
+	Type           string
+	GroupType      string
+	Instance       vault.Instance
+	Metadata       map[string]interface{}
+	Policies       []string
+	EntityIds      []string
+	MemberGroups   []string // names of member groups
+	MemberGroupIds []string
+	// member groups are declared with `member_groups`, the member groups of other groups,
+	// ex: derived from user roles, are left untouched
+	ManagesMemberGroups bool
+	Usernames           []string
+	// usernames of members of member groups, used for dry-run output
+	InheritedUsernames []string
+	// takes the ownership of an existing group that is not owned by vault-manager
+	Adopt bool
+	// lifecycle annotations of declared groups
+	Lifecycle toplevel.Lifecycle
 }
 
 func (g group) Key() string {
@@ -66,25 +92,84 @@ func (g group) KeyForDescription() string {
 	return fmt.Sprintf("%v", g.Metadata)
 }
 
+// Adopts returns whether the group takes the ownership of an existing group that is
+// not owned by vault-manager
+func (g group) Adopts() bool {
+	return g.Adopt
+}
+
+// ManagedBy returns the managed-by metadata of the group
+func (g group) ManagedBy() string {
+	managedBy, _ := g.Metadata[toplevel.ManagedByKey].(string)
+	return managedBy
+}
+
+// PreventsDestroy returns whether the group is declared with `prevent_destroy`
+func (g group) PreventsDestroy() bool {
+	return g.Lifecycle.PreventDestroy
+}
+
+// Retain returns the group where the metadata, policies and member groups ignored by its
+// lifecycle, as well as member groups it does not manage, take the value of the existing group
+func (g group) Retain(existing group) group {
+	if g.Lifecycle.Ignores("metadata") {
+		g.Metadata = nil
+		// the managed-by metadata is added to the metadata of desired groups
+		if existing.Metadata != nil {
+			g.Metadata = make(map[string]interface{}, len(existing.Metadata))
+			for k, v := range existing.Metadata {
+				g.Metadata[k] = v
+			}
+		}
+	}
+	if g.Lifecycle.Ignores("vault_policies") {
+		g.Policies = append([]string{}, existing.Policies...)
+	}
+	if !g.ManagesMemberGroups || g.Lifecycle.Ignores("member_groups") {
+		g.MemberGroups = append([]string{}, existing.MemberGroups...)
+	}
+	return g
+}
+
 func (g group) Equals(i interface{}) bool {
 	group, ok := i.(group)
 	if !ok {
 		return false
 	}
+	// members of external groups are managed by vault based on group aliases
 	return g.Name == group.Name &&
+		g.GroupType == group.GroupType &&
 		reflect.DeepEqual(g.Metadata, group.Metadata) &&
 		reflect.DeepEqual(g.Policies, group.Policies) &&
-		reflect.DeepEqual(g.EntityIds, group.EntityIds)
+		reflect.DeepEqual(g.MemberGroups, group.MemberGroups) &&
+		(g.GroupType == externalGroupType || reflect.DeepEqual(g.EntityIds, group.EntityIds))
+}
+
+func (g group) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"name":              g.Name,
+		"type":              g.GroupType,
+		"metadata":          g.Metadata,
+		"policies":          g.Policies,
+		"member_entity_ids": g.EntityIds,
+		"member_groups":     g.MemberGroups,
+	}
 }
 
-func (g group) CreateOrUpdate(action string) error {
+func (g group) CreateOrUpdate(ctx context.Context, action string) error {
 	path := filepath.Join("identity", g.Type, "name", g.Name)
 	config := map[string]interface{}{
-		"member_entity_ids": g.EntityIds,
-		"policies":          g.Policies,
-		"metadata":          g.Metadata,
+		"type":     g.GroupType,
+		"policies": g.Policies,
+		"metadata": g.Metadata,
+	}
+	if g.ManagesMemberGroups {
+		config["member_group_ids"] = g.MemberGroupIds
+	}
+	if g.GroupType != externalGroupType {
+		config["member_entity_ids"] = g.EntityIds
 	}
-	err := vault.WriteSecret(g.Instance.Address, path, vault.KV_V1, config)
+	err := vault.WriteSecret(ctx, g.Instance.Address, path, vault.KV_V1, config)
 	if err != nil {
 		return err
 	}
@@ -96,9 +181,9 @@ func (g group) CreateOrUpdate(action string) error {
 	return nil
 }
 
-func (g group) Delete() error {
+func (g group) Delete(ctx context.Context) error {
 	path := filepath.Join("identity", g.Type, "name", g.Name)
-	err := vault.DeleteSecret(g.Instance.Address, path)
+	err := vault.DeleteSecret(ctx, g.Instance.Address, path)
 	if err != nil {
 		return err
 	}
@@ -116,31 +201,51 @@ func init() {
 	toplevel.RegisterConfiguration(toplevelName, config{})
 }
 
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
-	var users []user
-	if err := yaml.Unmarshal(entriesBytes, &users); err != nil {
-		log.WithError(err).Fatal("[Vault Identity] failed to decode entity configuration")
+// Includes returns the key of declared groups, reconciled alongside the groups derived
+// from user roles so that neither are deleted as not desired by the other
+func (c config) Includes() []string {
+	return []string{declaredKey}
+}
+
+// Apply writes and updates groups in the write phase, member groups first, then their aliases.
+// Groups and aliases no longer desired are deleted in the delete phase.
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
+	var entries desiredState
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return vault.NewConfigError("[Vault Identity] failed to decode group configuration", err)
 	}
+	users, declaredGroups := entries.Users, entries.Declared
 
-	entityNamesToIds, err := getEntityNamesToIds(address)
+	entityNamesToIds, err := getEntityNamesToIds(ctx, address)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": address,
 		}).Info("[Vault Identity] failed to parse existing entities as prereq for group reconcile")
 		return err
 	}
+	// entities only planned to be written do not have an id yet during a dry run
+	for name := range plan.Overlay().Written(toplevel.EntityRef, address) {
+		if _, exists := entityNamesToIds[name]; !exists {
+			entityNamesToIds[name] = plannedEntityId(name)
+		}
+	}
 
 	desired := processDesired(address, users, entityNamesToIds)
+	declared, desiredAliases := processDeclared(address, declaredGroups)
+	desired = append(desired, declared...)
 	if unique := utils.ValidKeys(desired,
 		func(e group) string {
 			return e.Key()
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
-	desiredItems := asItems(desired)
+	order, err := orderByMembership(desired)
+	if err != nil {
+		return fmt.Errorf("[Vault Identity] invalid group membership within %s: %w", toplevelName, err)
+	}
 
-	existing, err := getExistingGroups(address, threadPoolSize)
+	existing, err := getExistingGroups(ctx, address, threadPoolSize)
 	if err != nil {
 		log.WithError(err).WithFields(log.Fields{
 			"instance": address,
@@ -148,42 +253,118 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		return err
 	}
 
+	// groups that are not owned by vault-manager are left untouched, as well as their aliases
+	desired, existing = toplevel.Owned(address, toplevelName, desired, existing)
+	desired = toplevel.IgnoreChanges(desired, existing)
+	if toplevel.MarksOwnership(toplevelName) {
+		markOwnership(desired)
+	}
+	desiredAliases = withOwnedGroups(desiredAliases, desired)
+
 	sortSlices(desired)
 	sortSlices(existing)
+	desiredItems := asItems(desired)
+
+	existingAliases, err := getExistingGroupAliases(ctx, address, existing)
+	if err != nil {
+		log.WithError(err).WithFields(log.Fields{
+			"instance": address,
+		}).Info("[Vault Identity] failed to retrieve existing group aliases")
+		return err
+	}
 
 	toBeWritten, toBeDeleted, toBeUpdated := vault.DiffItems(desiredItems, asItems(existing))
+
+	// the type of a group cannot be changed once created, such groups are deleted and written again
+	recreated := make(map[string]bool)
+	for _, e := range existing {
+		for _, u := range toBeUpdated {
+			if u.Key() == e.Name && u.(group).GroupType != e.GroupType {
+				recreated[e.Name] = true
+			}
+		}
+	}
+	// aliases of deleted and recreated groups are removed alongside the group
+	groupsToBeDeleted := append([]vault.Item{}, toBeDeleted...)
+	for _, u := range toBeUpdated {
+		if recreated[u.Key()] {
+			groupsToBeDeleted = append(groupsToBeDeleted, u)
+		}
+	}
+	// recreated groups lose their members and aliases, they count as deletions
+	err = toplevel.CheckDeletions(address, toplevelName, len(groupsToBeDeleted), len(existing))
+	if err != nil {
+		return err
+	}
+	err = toplevel.CheckDestroys(address, toplevelName, desired, groupsToBeDeleted)
+	if err != nil {
+		return err
+	}
+
+	if phase == toplevel.PhaseDelete {
+		plan.AddItems(address, toplevelName, toplevel.ActionDelete, toBeDeleted, nil)
+		if dryRun {
+			dryRunOutput(address, toBeDeleted, "deleted")
+		} else {
+			for _, d := range toBeDeleted {
+				err := d.(group).Delete(ctx)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return reconcileGroupAliases(ctx, phase, address, desiredAliases, existingAliases, groupsToBeDeleted, dryRun, plan)
+	}
+
+	plan.AddItems(address, toplevelName, toplevel.ActionWrite, toBeWritten, asItems(existing))
+	plan.AddItems(address, toplevelName, toplevel.ActionUpdate, toBeUpdated, asItems(existing))
 	if dryRun {
 		dryRunOutput(address, toBeWritten, "written")
-		dryRunOutput(address, toBeDeleted, "deleted")
 		dryRunOutput(address, toBeUpdated, "updated")
 
 		desired := getGroupsWithUsernames(desired, users, address)
 		existing := getGroupsWithUsernames(existing, users, address)
 
-		outputPolicyAffectedGroups(desired)
+		outputPolicyAffectedGroups(address, desired, plan)
 		outputGroupsWithPolicyChanges(existing, desired)
 	} else {
-		for _, w := range toBeWritten {
-			err := w.(group).CreateOrUpdate("written")
-			if err != nil {
-				return err
-			}
-		}
-		for _, d := range toBeDeleted {
-			err := d.(group).Delete()
-			if err != nil {
-				return err
-			}
+		groupIds := make(map[string]string)
+		for _, e := range existing {
+			groupIds[e.Name] = e.Id
 		}
+		// member groups must exist before the groups including them are written
+		updated := make(map[string]bool)
 		for _, u := range toBeUpdated {
-			err := u.(group).CreateOrUpdate("updated")
+			updated[u.Key()] = true
+		}
+		changes := append(append([]vault.Item{}, toBeWritten...), toBeUpdated...)
+		sortByMembership(changes, order)
+		for _, c := range changes {
+			g := c.(group)
+			if recreated[g.Name] {
+				err := g.Delete(ctx)
+				if err != nil {
+					return err
+				}
+				delete(groupIds, g.Name)
+			}
+			if g.ManagesMemberGroups {
+				err := resolveMemberGroupIds(ctx, address, &g, groupIds)
+				if err != nil {
+					return err
+				}
+			}
+			action := "written"
+			if updated[g.Name] {
+				action = "updated"
+			}
+			err = g.CreateOrUpdate(ctx, action)
 			if err != nil {
 				return err
 			}
 		}
 	}
-
-	return nil
+	return reconcileGroupAliases(ctx, phase, address, desiredAliases, existingAliases, groupsToBeDeleted, dryRun, plan)
 }
 
 // processDesired accepts the yaml-marshalled result of the `vault_groups` graphql
@@ -232,11 +413,13 @@ func handleNewDesired(processedGroups map[string]*group, permission oidcPermissi
 	// first occurrence of roleName (aka group name). Create the group and add entityID that referenced it
 	if _, exists := processedGroups[roleName]; !exists {
 		processedGroups[roleName] = &group{
-			Name:      roleName,
-			Type:      "group",
-			Instance:  permission.Instance,
-			EntityIds: []string{entityId}, // note that this could potentially be empty
-			Policies:  policies,
+			Name:         roleName,
+			Type:         "group",
+			GroupType:    internalGroupType,
+			Instance:     permission.Instance,
+			EntityIds:    []string{entityId}, // note that this could potentially be empty
+			MemberGroups: []string{},
+			Policies:     policies,
 			Metadata: map[string]interface{}{
 				permission.Name: permission.Description,
 			},
@@ -262,8 +445,8 @@ func handleNewDesired(processedGroups map[string]*group, permission oidcPermissi
 }
 
 // returns list of existing vault groups
-func getExistingGroups(instanceAddr string, threadPoolSize int) ([]group, error) {
-	raw, err := vault.ListGroups(instanceAddr)
+func getExistingGroups(ctx context.Context, instanceAddr string, threadPoolSize int) ([]group, error) {
+	raw, err := vault.ListGroups(ctx, instanceAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -304,33 +487,33 @@ func getExistingGroups(instanceAddr string, threadPoolSize int) ([]group, error)
 	// make separate call for each group to retrieve necessary details
 	bwg := utils.NewBoundedWaitGroup(threadPoolSize)
 
-	ch := make(chan error)
+	// buffered so that no goroutine blocks once the first error is returned
+	ch := make(chan error, len(processed))
 	for i := range processed {
 		bwg.Add(1)
-		go getGroupDetails(&processed[i], ch, &bwg)
+		go getGroupDetails(ctx, &processed[i], ch, &bwg)
 	}
 
-	// separate thread to wait and close channel
-	go func() {
-		bwg.Wait()
-		close(ch)
-	}()
+	// wait for all getGroupDetails goroutines to return
+	bwg.Wait()
+	close(ch)
 
-	// sit and wait for all getGroupDetails goroutines to return
+	// only the first error is reported
 	for err := range ch {
 		if err != nil {
 			return nil, err
 		}
 	}
 
+	populateMemberGroupNames(processed)
 	return processed, nil
 }
 
 // goroutine function
 // makes request to vault instance and updates a particular group object
-func getGroupDetails(g *group, ch chan<- error, wg *utils.BoundedWaitGroup) {
+func getGroupDetails(ctx context.Context, g *group, ch chan<- error, wg *utils.BoundedWaitGroup) {
 	defer wg.Done()
-	info, err := vault.GetGroupInfo(g.Instance.Address, g.Name)
+	info, err := vault.GetGroupInfo(ctx, g.Instance.Address, g.Name)
 	if err != nil {
 		ch <- err
 		return
@@ -375,13 +558,25 @@ func getGroupDetails(g *group, ch chan<- error, wg *utils.BoundedWaitGroup) {
 	if info["metadata"] != nil {
 		g.Metadata = info["metadata"].(map[string]interface{})
 	}
+
+	g.MemberGroupIds = []string{}
+	if ids, ok := info["member_group_ids"].([]interface{}); ok {
+		for _, id := range ids {
+			g.MemberGroupIds = append(g.MemberGroupIds, id.(string))
+		}
+	}
+
+	g.GroupType = internalGroupType
+	if groupType, ok := info["type"].(string); ok && groupType != "" {
+		g.GroupType = groupType
+	}
 }
 
 // processes result of ListEntites to build a map of entity names to Ids
 // this map is used to determine what groups should contain which entities
-func getEntityNamesToIds(instanceAddr string) (map[string]string, error) {
-	var entityNamesToIds map[string]string
-	raw, err := vault.ListEntities(instanceAddr)
+func getEntityNamesToIds(ctx context.Context, instanceAddr string) (map[string]string, error) {
+	entityNamesToIds := make(map[string]string)
+	raw, err := vault.ListEntities(ctx, instanceAddr)
 	if err != nil {
 		return nil, err
 	}
@@ -407,15 +602,28 @@ func getEntityNamesToIds(instanceAddr string) (map[string]string, error) {
 			return nil, errors.New(fmt.Sprintf(
 				"Required `name` attribute not found for entity id: %s", id))
 		}
-		if entityNamesToIds == nil {
-			entityNamesToIds = make(map[string]string)
-		}
 		name := values["name"].(string)
 		entityNamesToIds[name] = id
 	}
 	return entityNamesToIds, nil
 }
 
+// markOwnership adds the managed-by metadata to desired groups, so that they are owned
+// by vault-manager once written
+func markOwnership(groups []group) {
+	for i := range groups {
+		if groups[i].Metadata == nil {
+			groups[i].Metadata = make(map[string]interface{})
+		}
+		groups[i].Metadata[toplevel.ManagedByKey] = toplevel.ManagedByValue
+	}
+}
+
+// plannedEntityId is the placeholder id of an entity planned to be written during a dry run
+func plannedEntityId(name string) string {
+	return fmt.Sprintf("<planned entity %s>", name)
+}
+
 // return a new sorted group list that includes group member usernames
 func getGroupsWithUsernames(groups []group, users []user, instanceAddr string) []group {
 	groupMap := groupToMap(groups)
@@ -448,6 +656,12 @@ func getGroupsWithUsernames(groups []group, users []user, instanceAddr string) [
 		}
 	}
 
+	// users of member groups are effectively members of the groups including them
+	for name, g := range groupMap {
+		g.InheritedUsernames = inheritedUsernames(g, groupMap)
+		groupMap[name] = g
+	}
+
 	// and finally, convert that group map to a slice
 	desired := []group{}
 	for _, g := range groupMap {
@@ -462,9 +676,13 @@ func getGroupsWithUsernames(groups []group, users []user, instanceAddr string) [
 // Sorts slices of strings within each group object
 // Necessary for reflect.DeepEqual to be consistent in group.Equals()
 func sortSlices(groups []group) {
-	for _, group := range groups {
-		sort.Strings(group.Policies)
-		sort.Strings(group.EntityIds)
+	for i := range groups {
+		sort.Strings(groups[i].Policies)
+		sort.Strings(groups[i].EntityIds)
+		if groups[i].MemberGroups == nil {
+			groups[i].MemberGroups = []string{}
+		}
+		sort.Strings(groups[i].MemberGroups)
 	}
 }
 
@@ -488,18 +706,19 @@ func dryRunOutput(instanceAddr string, groups []vault.Item, action string) {
 }
 
 // Output a list of groups and counts of users that will be affected by policy changes
-func outputPolicyAffectedGroups(desired []group) {
-	policyActions := toplevel.GetPolicies()
+func outputPolicyAffectedGroups(instanceAddr string, desired []group, plan *toplevel.Plan) {
+	// changes of policies were planned by the policies configuration, applied before groups
+	policyChanges := plan.Overlay().Entries(toplevel.PolicyRef, instanceAddr)
 
-	if len(policyActions) == 0 {
+	if len(policyChanges) == 0 {
 		return
 	}
 
 	for _, d := range desired {
 		for _, p := range d.Policies {
-			action, ok := policyActions[p]
+			planned, ok := policyChanges[p]
 			if ok {
-				action := toplevel.PrintPolicyAction(action)
+				action := toplevel.PrintPolicyAction(planned.Action)
 				// this group will be affected by the policy change
 				log.WithFields(log.Fields{
 					"policy":   p,
@@ -507,19 +726,27 @@ func outputPolicyAffectedGroups(desired []group) {
 					"action":   action,
 					"instance": d.Instance.Address,
 				}).Infof("[Dry Run] [Vault Identity] %d user(s) in group: '%s' will have policy: '%s' %s", len(d.EntityIds), d.Name, p, action)
-				outputUserList(d.Usernames, d.Name, d.Instance.Address)
+				outputUserList(d)
 			}
 		}
 	}
 }
 
 // output list of users with newlines
-func outputUserList(users []string, groupName string, instance string) {
+func outputUserList(g group) {
 	log.WithFields(log.Fields{
-		"group":    groupName,
-		"users":    users,
-		"instance": instance,
+		"group":    g.Name,
+		"users":    g.Usernames,
+		"instance": g.Instance.Address,
 	}).Info("[Dry Run] [Vault Identity] Affected user list")
+	if len(g.InheritedUsernames) > 0 {
+		log.WithFields(log.Fields{
+			"group":        g.Name,
+			"memberGroups": g.MemberGroups,
+			"users":        g.InheritedUsernames,
+			"instance":     g.Instance.Address,
+		}).Info("[Dry Run] [Vault Identity] Affected user list inherited through member groups")
+	}
 }
 
 // Compare existing and desired groups to determine who is affected by policy additions and removals
@@ -536,7 +763,7 @@ func outputGroupsWithPolicyChanges(existing []group, desired []group) {
 				"groupPolicies": e.Policies,
 				"instance":      e.Instance.Address,
 			}).Infof("[Dry Run] [Vault Identity] %d user(s) are in the group to be deleted", len(e.EntityIds))
-			outputUserList(e.Usernames, e.Name, e.Instance.Address)
+			outputUserList(e)
 		} else {
 			comparePoliciesBetweenGroups(e, d)
 		}
@@ -550,7 +777,7 @@ func outputGroupsWithPolicyChanges(existing []group, desired []group) {
 				"instance":      d.Instance.Address,
 				"groupPolicies": d.Policies,
 			}).Infof("[Dry Run] [Vault Identity] %d user(s) are in the group to be created", len(d.EntityIds))
-			outputUserList(d.Usernames, d.Name, d.Instance.Address)
+			outputUserList(d)
 		}
 	}
 
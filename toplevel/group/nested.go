@@ -0,0 +1,140 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// orderByMembership returns the position of each group within an order where member
+// groups precede the groups including them. an error is returned when a group includes
+// an undeclared group or when membership is cyclic
+func orderByMembership(groups []group) (map[string]int, error) {
+	groupMap := groupToMap(groups)
+	// sorted for a predictable order between unrelated groups
+	names := make([]string, 0, len(groupMap))
+	for name := range groupMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int)
+	order := make(map[string]int)
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("cycle detected within member groups: %s -> %s", strings.Join(path, " -> "), name)
+		}
+		state[name] = visiting
+		for _, member := range groupMap[name].MemberGroups {
+			if _, exists := groupMap[member]; !exists {
+				return fmt.Errorf("group `%s` includes undeclared member group `%s`", name, member)
+			}
+			if err := visit(member, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		order[name] = len(order)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, []string{}); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// sorts groups so that member groups are written before the groups including them
+func sortByMembership(items []vault.Item, order map[string]int) {
+	sort.SliceStable(items, func(i, j int) bool {
+		return order[items[i].Key()] < order[items[j].Key()]
+	})
+}
+
+// resolves the ids of the member groups of g. ids of groups that did not exist prior to
+// reconcile are retrieved from vault and added to groupIds
+func resolveMemberGroupIds(ctx context.Context, instanceAddr string, g *group, groupIds map[string]string) error {
+	g.MemberGroupIds = []string{}
+	for _, name := range g.MemberGroups {
+		if _, exists := groupIds[name]; !exists {
+			info, err := vault.GetGroupInfo(ctx, instanceAddr, name)
+			if err != nil {
+				return err
+			}
+			if info == nil {
+				return errors.New(fmt.Sprintf(
+					"[Vault Identity] failed to get info for member group: %s", name))
+			}
+			groupIds[name] = info["id"].(string)
+		}
+		g.MemberGroupIds = append(g.MemberGroupIds, groupIds[name])
+	}
+	sort.Strings(g.MemberGroupIds)
+	return nil
+}
+
+// sets the names of member groups of existing groups from the ids returned by vault
+func populateMemberGroupNames(groups []group) {
+	groupIdsToNames := make(map[string]string)
+	for _, g := range groups {
+		groupIdsToNames[g.Id] = g.Name
+	}
+	for i := range groups {
+		groups[i].MemberGroups = []string{}
+		for _, id := range groups[i].MemberGroupIds {
+			name, exists := groupIdsToNames[id]
+			if !exists {
+				// keep unknown ids visible within output
+				name = id
+			}
+			groups[i].MemberGroups = append(groups[i].MemberGroups, name)
+		}
+	}
+}
+
+// returns the sorted usernames a group inherits through its member groups, excluding direct members
+func inheritedUsernames(g group, groupMap map[string]group) []string {
+	direct := make(map[string]bool)
+	for _, u := range g.Usernames {
+		direct[u] = true
+	}
+	inherited := make(map[string]bool)
+	seen := map[string]bool{g.Name: true}
+	var collect func(names []string)
+	collect = func(names []string) {
+		for _, name := range names {
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			member := groupMap[name]
+			for _, u := range member.Usernames {
+				if !direct[u] {
+					inherited[u] = true
+				}
+			}
+			collect(member.MemberGroups)
+		}
+	}
+	collect(g.MemberGroups)
+
+	usernames := []string{}
+	for u := range inherited {
+		usernames = append(usernames, u)
+	}
+	sort.Strings(usernames)
+	return usernames
+}
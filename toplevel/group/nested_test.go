@@ -0,0 +1,76 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderByMembership(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		given       []group
+		expected    []string
+		expectedErr string
+	}{
+		{
+			"member groups precede the groups including them",
+			[]group{
+				{Name: "sre-all", MemberGroups: []string{"sre-oncall", "sre-readonly"}},
+				{Name: "sre-oncall", MemberGroups: []string{"sre-readonly"}},
+				{Name: "sre-readonly"},
+			},
+			[]string{"sre-readonly", "sre-oncall", "sre-all"},
+			"",
+		},
+		{
+			"cyclic membership",
+			[]group{
+				{Name: "a", MemberGroups: []string{"b"}},
+				{Name: "b", MemberGroups: []string{"c"}},
+				{Name: "c", MemberGroups: []string{"a"}},
+			},
+			nil,
+			"cycle detected within member groups: a -> b -> c -> a",
+		},
+		{
+			"undeclared member group",
+			[]group{
+				{Name: "a", MemberGroups: []string{"b"}},
+			},
+			nil,
+			"group `a` includes undeclared member group `b`",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			order, err := orderByMembership(c.given)
+			if c.expectedErr != "" {
+				require.EqualError(t, err, c.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			for i := 1; i < len(c.expected); i++ {
+				require.Less(t, order[c.expected[i-1]], order[c.expected[i]])
+			}
+		})
+	}
+}
+
+func TestInheritedUsernames(t *testing.T) {
+	t.Parallel()
+
+	groupMap := groupToMap([]group{
+		{Name: "sre-all", MemberGroups: []string{"sre-oncall"}, Usernames: []string{"alice"}},
+		{Name: "sre-oncall", MemberGroups: []string{"sre-readonly"}, Usernames: []string{"bob", "alice"}},
+		{Name: "sre-readonly", Usernames: []string{"carol"}},
+	})
+
+	require.Equal(t, []string{"bob", "carol"}, inheritedUsernames(groupMap["sre-all"], groupMap))
+	require.Equal(t, []string{}, inheritedUsernames(groupMap["sre-readonly"], groupMap))
+}
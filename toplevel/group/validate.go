@@ -0,0 +1,91 @@
+package group
+
+import (
+	"sort"
+
+	"github.com/app-sre/vault-manager/pkg/utils"
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks the users of `vault_groups` and the fields of declared groups, as well as
+// the uniqueness and the membership of all groups per instance. Policies and auth backends
+// of group aliases are referenced so that they can be checked against declared ones.
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries desiredState
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+	users, declaredGroups := entries.Users, entries.Declared
+
+	problems := []toplevel.Problem{}
+	instances := make(map[string]bool)
+	for _, u := range users {
+		// ex: a group declared within `vault_groups` rather than `vault_declared_groups`
+		problems = append(problems, toplevel.Problem{Toplevel: toplevelName}.Require("org_username", u.Name)...)
+		for _, r := range u.Roles {
+			for _, permission := range r.Permissions {
+				if permission.Service != "vault" {
+					continue
+				}
+				instances[permission.Instance.Address] = true
+				p := toplevel.Problem{Toplevel: toplevelName, Instance: permission.Instance.Address, Entry: r.Name}
+				for _, policy := range permission.Policies {
+					refs.Reference(toplevel.PolicyRef, policy.Name, p)
+				}
+			}
+		}
+	}
+
+	for _, e := range declaredGroups {
+		instances[e.Instance.Address] = true
+		p := toplevel.Problem{Toplevel: declaredKey, Instance: e.Instance.Address, Entry: e.Name}
+		problems = append(problems, p.Require("name", e.Name)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		switch e.Type {
+		case "":
+			problems = append(problems, p.Require("type", e.Type)...)
+		case internalGroupType:
+			if e.GroupsClaim != "" {
+				problems = append(problems, p.Withf("`groups_claim` is only supported by %s groups", externalGroupType))
+			}
+		case externalGroupType:
+			if e.GroupsClaim != "" {
+				mount := e.Mount
+				if mount == "" {
+					mount = defaultAliasMount
+				}
+				refs.Reference(toplevel.AuthBackendRef, mount, p)
+			}
+		default:
+			problems = append(problems, p.Withf("`type` must be one of `%s` or `%s`, got `%s`",
+				internalGroupType, externalGroupType, e.Type))
+		}
+		for _, policy := range e.Policies {
+			refs.Reference(toplevel.PolicyRef, policy.Name, p)
+		}
+	}
+
+	addresses := make([]string, 0, len(instances))
+	for address := range instances {
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		p := toplevel.Problem{Toplevel: toplevelName, Instance: address}
+		declared, _ := processDeclared(address, declaredGroups)
+		groups := append(processDesired(address, users, nil), declared...)
+		if unique := utils.ValidKeys(groups, func(g group) string { return g.Key() }); !unique {
+			problems = append(problems, p.Withf("duplicate group name within %s", toplevelName))
+			continue
+		}
+		if _, err := orderByMembership(groups); err != nil {
+			problems = append(problems, p.Withf("invalid group membership: %v", err))
+		}
+	}
+	return problems
+}
@@ -0,0 +1,177 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		groups      string
+		expected    []string
+	}{
+		{
+			"valid groups",
+			`
+vault_groups:
+- org_username: user
+  roles:
+  - name: app
+    oidc_permissions:
+    - name: app
+      service: vault
+      instance:
+        address: https://vault
+      vault_policies:
+      - name: declared
+vault_declared_groups:
+- name: platform
+  type: external
+  groups_claim: platform
+  instance:
+    address: https://vault
+  member_groups:
+  - name: app
+`,
+			[]string{},
+		},
+		{
+			"declared group within vault_groups",
+			`
+vault_groups:
+- name: platform
+  type: internal
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `org_username`"},
+		},
+		{
+			"declared group without type",
+			`
+vault_declared_groups:
+- name: platform
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `type`"},
+		},
+		{
+			"users as a list",
+			`
+- org_username: user
+`,
+			[]string{"failed to decode configuration: yaml: unmarshal errors:\n  line 2: cannot unmarshal !!seq into group.desiredState"},
+		},
+		{
+			"groups claim of an internal group",
+			`
+vault_declared_groups:
+- name: platform
+  type: internal
+  groups_claim: platform
+  instance:
+    address: https://vault
+`,
+			[]string{"`groups_claim` is only supported by external groups"},
+		},
+		{
+			"invalid type",
+			`
+vault_declared_groups:
+- name: platform
+  type: okta
+  instance:
+    address: https://vault
+`,
+			[]string{"`type` must be one of `internal` or `external`, got `okta`"},
+		},
+		{
+			"declared group named after the group of a role",
+			`
+vault_groups:
+- org_username: user
+  roles:
+  - name: app
+    oidc_permissions:
+    - name: app
+      service: vault
+      instance:
+        address: https://vault
+vault_declared_groups:
+- name: app
+  type: internal
+  instance:
+    address: https://vault
+`,
+			[]string{"duplicate group name within vault_groups"},
+		},
+		{
+			"membership cycle",
+			`
+vault_declared_groups:
+- name: platform
+  type: internal
+  instance:
+    address: https://vault
+  member_groups:
+  - name: platform
+`,
+			[]string{"invalid group membership: cycle detected within member groups: platform -> platform"},
+		},
+		{
+			"permission referencing an undeclared policy",
+			`
+vault_groups:
+- org_username: user
+  roles:
+  - name: app
+    oidc_permissions:
+    - name: app
+      service: vault
+      instance:
+        address: https://vault
+      vault_policies:
+      - name: undeclared
+`,
+			[]string{"references policy `undeclared` which is not declared for the instance"},
+		},
+		{
+			"groups claim on an undeclared auth backend",
+			`
+vault_declared_groups:
+- name: platform
+  type: external
+  groups_claim: platform
+  mount: github
+  instance:
+    address: https://vault
+`,
+			[]string{"references auth backend `github` which is not declared for the instance"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			refs := toplevel.NewReferences()
+			refs.Manages(toplevel.PolicyRef)
+			refs.Manages(toplevel.AuthBackendRef)
+			refs.Declare(toplevel.PolicyRef, "https://vault", "declared")
+			refs.Declare(toplevel.AuthBackendRef, "https://vault", "oidc/")
+
+			problems := config{}.Validate([]byte(c.groups), refs)
+			messages := []string{}
+			for _, p := range append(problems, refs.Dangling()...) {
+				messages = append(messages, p.Message)
+			}
+			require.Equal(t, c.expected, messages)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package toplevel
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// Lifecycle holds the lifecycle annotations of a desired entry, ex:
+//
+//	lifecycle:
+//	  prevent_destroy: true
+//	  ignore_changes: [description, max_lease_ttl]
+type Lifecycle struct {
+	// fails any run that would delete the entry, ex: to recreate it
+	PreventDestroy bool `yaml:"prevent_destroy"`
+	// fields or options whose changes made out-of-band are kept
+	IgnoreChanges []string `yaml:"ignore_changes"`
+}
+
+// Ignores returns whether changes of a field or option are ignored
+func (l Lifecycle) Ignores(key string) bool {
+	for _, k := range l.IgnoreChanges {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Protected is implemented by desired entries that may be declared with `prevent_destroy`
+type Protected interface {
+	PreventsDestroy() bool
+}
+
+// Retainer is implemented by desired entries that may be declared with `ignore_changes`
+type Retainer[T any] interface {
+	vault.Item
+	// Retain returns the entry where the fields and options ignored by its lifecycle
+	// take the value of the existing entry
+	Retain(existing T) T
+}
+
+// Retain returns a copy of desired options where the options ignored by the lifecycle
+// take their existing value, or are left out when they do not exist
+func Retain[V any](l Lifecycle, desired, existing map[string]V) map[string]V {
+	if len(l.IgnoreChanges) == 0 {
+		return desired
+	}
+	retained := make(map[string]V, len(desired))
+	for k, v := range desired {
+		if !l.Ignores(k) {
+			retained[k] = v
+		}
+	}
+	for _, k := range l.IgnoreChanges {
+		if v, ok := existing[k]; ok {
+			retained[k] = v
+		}
+	}
+	return retained
+}
+
+// IgnoreChanges returns the desired entries where the fields and options listed by
+// `ignore_changes` take the value of the existing entry of the same key, so that their
+// changes are neither compared nor written. Desired values are written when the entry
+// does not exist yet.
+func IgnoreChanges[T Retainer[T]](desired, existing []T) []T {
+	byKey := make(map[string]T, len(existing))
+	for _, e := range existing {
+		byKey[e.Key()] = e
+	}
+	retained := make([]T, 0, len(desired))
+	for _, d := range desired {
+		if e, ok := byKey[d.Key()]; ok {
+			d = d.Retain(e)
+		}
+		retained = append(retained, d)
+	}
+	return retained
+}
+
+// protectedKeys holds, per instance and top-level configuration, the keys of the entries
+// declared with `prevent_destroy` by the last desired state checked without error
+var (
+	protectedKeys  = make(map[string]map[string]bool)
+	protectedKeysM sync.Mutex
+)
+
+// CheckDestroys returns a *vault.ConfigError when entries to be deleted from an instance
+// are protected: either the desired entry of the same key, ex: to be recreated, is declared
+// with `prevent_destroy`, or the entry is no longer desired but was protected by the last
+// desired state checked within this process. Like deletions, destroys are checked in both
+// phases before any change is made.
+func CheckDestroys[T vault.Item](instance, toplevelName string, desired []T, toBeDeleted []vault.Item) error {
+	protected := make(map[string]bool, len(desired))
+	for _, d := range desired {
+		p, ok := any(d).(Protected)
+		protected[strings.Trim(d.Key(), "/")] = ok && p.PreventsDestroy()
+	}
+
+	protectedKeysM.Lock()
+	defer protectedKeysM.Unlock()
+	recorded := protectedKeys[instance+"/"+toplevelName]
+	prevented := []string{}
+	for _, d := range toBeDeleted {
+		key := strings.Trim(d.Key(), "/")
+		if p, ok := protected[key]; p || !ok && recorded[key] {
+			prevented = append(prevented, d.Key())
+		}
+	}
+	if len(prevented) > 0 {
+		return vault.NewConfigError(
+			fmt.Sprintf("[%s] %s would delete entries declared with `prevent_destroy`", instance, toplevelName),
+			errors.New(strings.Join(prevented, ", ")))
+	}
+
+	// the entries are protected until removed from the desired state once no longer
+	// declared with `prevent_destroy`
+	record := make(map[string]bool)
+	for key, p := range protected {
+		if p {
+			record[key] = true
+		}
+	}
+	protectedKeys[instance+"/"+toplevelName] = record
+	return nil
+}
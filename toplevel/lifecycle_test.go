@@ -0,0 +1,202 @@
+package toplevel
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+type guardedEntry struct {
+	name        string
+	description string
+	options     map[string]interface{}
+	lifecycle   Lifecycle
+}
+
+func (e guardedEntry) Key() string { return e.name }
+func (e guardedEntry) Equals(i interface{}) bool {
+	existing, ok := i.(guardedEntry)
+	return ok && vault.EqualPathNames(e.name, existing.name)
+}
+func (e guardedEntry) KeyForDescription() string { return e.description }
+func (e guardedEntry) KeyForType() string        { return "entry" }
+func (e guardedEntry) PreventsDestroy() bool     { return e.lifecycle.PreventDestroy }
+
+func (e guardedEntry) Retain(existing guardedEntry) guardedEntry {
+	if e.lifecycle.Ignores("description") {
+		e.description = existing.description
+	}
+	e.options = Retain(e.lifecycle, e.options, existing.options)
+	return e
+}
+
+func TestRetain(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		ignored     []string
+		desired     map[string]interface{}
+		existing    map[string]interface{}
+		expected    map[string]interface{}
+	}{
+		{
+			"without ignored changes",
+			nil,
+			map[string]interface{}{"ttl": "1h"},
+			map[string]interface{}{"ttl": "2h"},
+			map[string]interface{}{"ttl": "1h"},
+		},
+		{
+			"ignored option keeps its existing value",
+			[]string{"ttl"},
+			map[string]interface{}{"ttl": "1h", "period": "1h"},
+			map[string]interface{}{"ttl": "2h", "period": "2h"},
+			map[string]interface{}{"ttl": "2h", "period": "1h"},
+		},
+		{
+			"ignored option is left out when not existing",
+			[]string{"ttl"},
+			map[string]interface{}{"ttl": "1h"},
+			map[string]interface{}{},
+			map[string]interface{}{},
+		},
+		{
+			"ignored option set out-of-band",
+			[]string{"ttl"},
+			map[string]interface{}{},
+			map[string]interface{}{"ttl": "2h"},
+			map[string]interface{}{"ttl": "2h"},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, c.expected, Retain(Lifecycle{IgnoreChanges: c.ignored}, c.desired, c.existing))
+		})
+	}
+}
+
+func TestIgnoreChanges(t *testing.T) {
+	t.Parallel()
+
+	ignored := Lifecycle{IgnoreChanges: []string{"description", "ttl"}}
+	desired := []guardedEntry{
+		{name: "a", description: "desired", options: map[string]interface{}{"ttl": "1h"}, lifecycle: ignored},
+		{name: "b", description: "desired", options: map[string]interface{}{"ttl": "1h"}},
+		// written with its desired values
+		{name: "c", description: "desired", options: map[string]interface{}{"ttl": "1h"}, lifecycle: ignored},
+	}
+	existing := []guardedEntry{
+		{name: "a", description: "out-of-band", options: map[string]interface{}{"ttl": "2h"}},
+		{name: "b", description: "out-of-band", options: map[string]interface{}{"ttl": "2h"}},
+	}
+
+	retained := IgnoreChanges(desired, existing)
+	require.Equal(t, []guardedEntry{
+		{name: "a", description: "out-of-band", options: map[string]interface{}{"ttl": "2h"}, lifecycle: ignored},
+		desired[1],
+		desired[2],
+	}, retained)
+}
+
+func TestCheckDestroys(t *testing.T) {
+	t.Parallel()
+
+	protected := Lifecycle{PreventDestroy: true}
+	existing := []guardedEntry{{name: "kv/"}, {name: "audit/"}, {name: "approle/"}, {name: "removed/"}}
+
+	// runs against the same instance, in order
+	cases := []struct {
+		description string
+		desired     []guardedEntry
+		recreated   []vault.Item
+		expected    string
+	}{
+		{
+			"undeclared entry without recorded protection",
+			[]guardedEntry{{name: "kv/", lifecycle: protected}, {name: "audit/", lifecycle: protected}, {name: "approle/"}},
+			nil,
+			"",
+		},
+		{
+			"recreated entry declared with prevent_destroy",
+			[]guardedEntry{{name: "kv/", lifecycle: protected}, {name: "audit/", lifecycle: protected}, {name: "approle/"}},
+			[]vault.Item{guardedEntry{name: "kv/"}},
+			"[https://destroys] vault_guarded would delete entries declared with `prevent_destroy`: kv/",
+		},
+		{
+			"removed entries protected by the last checked desired state",
+			[]guardedEntry{{name: "approle/"}},
+			nil,
+			"[https://destroys] vault_guarded would delete entries declared with `prevent_destroy`: kv/, audit/",
+		},
+		{
+			"protection lifted",
+			[]guardedEntry{{name: "kv/"}, {name: "audit/", lifecycle: protected}, {name: "approle/"}},
+			nil,
+			"",
+		},
+		{
+			"entry removed once no longer protected",
+			[]guardedEntry{{name: "audit/", lifecycle: protected}, {name: "approle/"}},
+			nil,
+			"",
+		},
+	}
+
+	for _, c := range cases {
+		toBeWritten, toBeDeleted, _ := vault.DiffItems(asItems(c.desired), asItems(existing))
+		require.Empty(t, toBeWritten, c.description)
+		err := CheckDestroys("https://destroys", "vault_guarded", c.desired, append(toBeDeleted, c.recreated...))
+		if c.expected == "" {
+			require.NoError(t, err, c.description)
+			continue
+		}
+		require.EqualError(t, err, c.expected, c.description)
+		require.Equal(t, vault.ConfigErrorKind, vault.ErrorKind(err), c.description)
+	}
+}
+
+func asItems(entries []guardedEntry) []vault.Item {
+	items := make([]vault.Item, 0, len(entries))
+	for _, e := range entries {
+		items = append(items, e)
+	}
+	return items
+}
+
+func TestLifecycleUnmarshal(t *testing.T) {
+	t.Parallel()
+
+	// graphql returns null for lifecycle annotations that are not set
+	cases := []struct {
+		description string
+		lifecycle   string
+		expected    Lifecycle
+	}{
+		{"null lifecycle", "lifecycle: null", Lifecycle{}},
+		{"null annotations", "lifecycle: {prevent_destroy: null, ignore_changes: null}", Lifecycle{}},
+		{
+			"set annotations",
+			"lifecycle: {prevent_destroy: true, ignore_changes: [description]}",
+			Lifecycle{PreventDestroy: true, IgnoreChanges: []string{"description"}},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			var entry struct {
+				Lifecycle Lifecycle `yaml:"lifecycle"`
+			}
+			require.NoError(t, yaml.Unmarshal([]byte(c.lifecycle), &entry))
+			require.Equal(t, c.expected, entry.Lifecycle)
+		})
+	}
+}
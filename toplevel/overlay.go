@@ -0,0 +1,88 @@
+package toplevel
+
+import (
+	"sync"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// EntityRef is the kind of identity entities, only recorded within an Overlay
+const EntityRef ReferenceKind = "entity"
+
+// Planned is an entry planned to be written or deleted
+type Planned struct {
+	Action Action
+	Type   string
+	// options of auth backends and secrets engines, ex: the version of a kv engine
+	Options map[string]string
+}
+
+// Overlay records the entries planned by the configurations applied before during a
+// dry run. As planned changes are not made to the instance, the configurations
+// applied next consult the overlay alongside the existing state of the instance,
+// ex: roles of an auth backend or approle credentials within a kv engine which are
+// only planned to be enabled.
+//
+// A nil overlay, the overlay of a plan that is not a dry run, records nothing.
+type Overlay struct {
+	m sync.Mutex
+	// instance to kind to name of the entry
+	entries map[string]map[ReferenceKind]map[string]Planned
+}
+
+// Overlay returns the overlay of the plan, nil unless the plan is a dry run
+func (p *Plan) Overlay() *Overlay {
+	if p == nil {
+		return nil
+	}
+	return p.overlay
+}
+
+// Record records that an entry of a kind is planned to be written or deleted on an instance
+func (o *Overlay) Record(kind ReferenceKind, instance, name string, planned Planned) {
+	if o == nil {
+		return
+	}
+	o.m.Lock()
+	defer o.m.Unlock()
+	if o.entries[instance] == nil {
+		o.entries[instance] = make(map[ReferenceKind]map[string]Planned)
+	}
+	if o.entries[instance][kind] == nil {
+		o.entries[instance][kind] = make(map[string]Planned)
+	}
+	o.entries[instance][kind][name] = planned
+}
+
+// Entries returns the entries of a kind planned on an instance by name
+func (o *Overlay) Entries(kind ReferenceKind, instance string) map[string]Planned {
+	entries := make(map[string]Planned)
+	if o == nil {
+		return entries
+	}
+	o.m.Lock()
+	defer o.m.Unlock()
+	for name, planned := range o.entries[instance][kind] {
+		entries[name] = planned
+	}
+	return entries
+}
+
+// Written returns the entries of a kind planned to be written on an instance by name
+func (o *Overlay) Written(kind ReferenceKind, instance string) map[string]Planned {
+	written := make(map[string]Planned)
+	for name, planned := range o.Entries(kind, instance) {
+		if planned.Action == ActionWrite {
+			written[name] = planned
+		}
+	}
+	return written
+}
+
+// RecordItems records that items of a kind are planned to be written or deleted on an
+// instance, the type of an item being its KeyForType
+func (o *Overlay) RecordItems(kind ReferenceKind, instance string, action Action, items []vault.Item) {
+	for _, item := range items {
+		o.Record(kind, instance, item.Key(), Planned{Action: action, Type: item.KeyForType()})
+	}
+}
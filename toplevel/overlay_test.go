@@ -0,0 +1,37 @@
+package toplevel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlay(t *testing.T) {
+	t.Parallel()
+
+	plan := NewPlan(true)
+	overlay := plan.Overlay()
+	overlay.Record(SecretEngineRef, "https://a", "app/kv/", Planned{
+		Action:  ActionWrite,
+		Type:    "kv",
+		Options: map[string]string{"version": "2"},
+	})
+	overlay.Record(SecretEngineRef, "https://a", "app/old/", Planned{Action: ActionDelete, Type: "kv"})
+	overlay.Record(SecretEngineRef, "https://b", "other/", Planned{Action: ActionWrite, Type: "kv"})
+
+	require.Len(t, overlay.Entries(SecretEngineRef, "https://a"), 2)
+	require.Equal(t, map[string]Planned{
+		"app/kv/": {Action: ActionWrite, Type: "kv", Options: map[string]string{"version": "2"}},
+	}, overlay.Written(SecretEngineRef, "https://a"))
+	require.Empty(t, overlay.Entries(AuthBackendRef, "https://a"))
+}
+
+func TestOverlayWithoutDryRun(t *testing.T) {
+	t.Parallel()
+
+	// planned changes are made to the instance before the next configuration is applied
+	overlay := NewPlan(false).Overlay()
+	overlay.Record(PolicyRef, "https://a", "policy", Planned{Action: ActionWrite})
+	require.Nil(t, overlay)
+	require.Empty(t, overlay.Entries(PolicyRef, "https://a"))
+}
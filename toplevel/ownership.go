@@ -0,0 +1,188 @@
+package toplevel
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	log "github.com/sirupsen/logrus"
+)
+
+// metadata marking entities and groups written by vault-manager
+const (
+	ManagedByKey   = "managed-by"
+	ManagedByValue = "vault-manager"
+)
+
+// Ownership decides which entries of a top-level configuration existing on an instance
+// are owned by vault-manager. Entries that are not owned are neither updated nor deleted.
+// An entry is owned when any rule matches it.
+type Ownership struct {
+	prefixes []string
+	globs    []string
+	// entries marked with the managed-by metadata are owned
+	marker bool
+}
+
+// Adopter is implemented by desired entries that may take the ownership of an existing
+// entry that is not owned, ex: with `adopt: true`
+type Adopter interface {
+	Adopts() bool
+}
+
+// Marked is implemented by entries with metadata, returning their managed-by metadata
+type Marked interface {
+	ManagedBy() string
+}
+
+// OwnershipPather is implemented by entries matched by prefixes and globs on another
+// path than their key, ex: roles are matched on `MOUNT/NAME`
+type OwnershipPather interface {
+	OwnershipPath() string
+}
+
+func ownershipPath(item vault.Item) string {
+	if p, ok := item.(OwnershipPather); ok {
+		return p.OwnershipPath()
+	}
+	return item.Key()
+}
+
+// owns returns whether item is owned. Desired entries, not existing yet, are owned
+// when they will be written with the managed-by metadata
+func (o *Ownership) owns(item vault.Item, exists bool) bool {
+	p := ownershipPath(item)
+	for _, prefix := range o.prefixes {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+	for _, glob := range o.globs {
+		if matched, _ := path.Match(glob, p); matched {
+			return true
+		}
+	}
+	if m, ok := item.(Marked); ok && o.marker {
+		return !exists || m.ManagedBy() == ManagedByValue
+	}
+	return false
+}
+
+// ParseOwnership parses a comma separated list of `TOPLEVEL=RULE` rules. RULE is
+// `prefix:PREFIX` or `glob:PATTERN`, matching the name or path of entries, or
+// `metadata`, matching entities and groups marked with `managed-by: vault-manager`.
+// Every entry of a configuration without rule is owned.
+func ParseOwnership(spec string) (map[string]*Ownership, error) {
+	ownerships := make(map[string]*Ownership)
+	if strings.TrimSpace(spec) == "" {
+		return ownerships, nil
+	}
+
+	configsM.RLock()
+	defer configsM.RUnlock()
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		name, value, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid ownership rule %q, must be `TOPLEVEL=RULE`", rule)
+		}
+		if _, ok := configs[name]; !ok {
+			return nil, fmt.Errorf("unknown top-level configuration `%s` of ownership rule %q", name, rule)
+		}
+		o := ownerships[name]
+		if o == nil {
+			o = &Ownership{}
+			ownerships[name] = o
+		}
+		kind, arg, _ := strings.Cut(value, ":")
+		switch {
+		case kind == "prefix" && arg != "":
+			o.prefixes = append(o.prefixes, arg)
+		case kind == "glob" && arg != "":
+			if _, err := path.Match(arg, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob of ownership rule %q: %w", rule, err)
+			}
+			o.globs = append(o.globs, arg)
+		case value == "metadata":
+			o.marker = true
+		default:
+			return nil, fmt.Errorf("invalid ownership rule %q, must be `prefix:PREFIX`, `glob:PATTERN` or `metadata`", rule)
+		}
+	}
+	return ownerships, nil
+}
+
+var ownerships atomic.Pointer[map[string]*Ownership]
+
+// SetOwnership sets the ownership rules of the configurations by name
+func SetOwnership(o map[string]*Ownership) {
+	ownerships.Store(&o)
+}
+
+func ownershipOf(toplevelName string) *Ownership {
+	o := ownerships.Load()
+	if o == nil {
+		return nil
+	}
+	return (*o)[toplevelName]
+}
+
+// MarksOwnership returns whether the entries written by a configuration must be marked
+// with the managed-by metadata
+func MarksOwnership(toplevelName string) bool {
+	o := ownershipOf(toplevelName)
+	return o != nil && o.marker
+}
+
+// Owned returns the desired and existing entries of a configuration applied to an instance
+// that are owned. Existing entries that are not owned are left untouched, unless a desired
+// entry of the same key adopts them. Desired entries that are not owned once written, or
+// that would overwrite an existing entry that is not owned, are skipped with a warning.
+func Owned[T vault.Item](instance, toplevelName string, desired, existing []T) ([]T, []T) {
+	return ownedBy(ownershipOf(toplevelName), instance, toplevelName, desired, existing)
+}
+
+func ownedBy[T vault.Item](o *Ownership, instance, toplevelName string, desired, existing []T) ([]T, []T) {
+	if o == nil {
+		return desired, existing
+	}
+	adopted := make(map[string]bool)
+	for _, d := range desired {
+		if a, ok := any(d).(Adopter); ok && a.Adopts() {
+			adopted[d.Key()] = true
+		}
+	}
+
+	ownedExisting := make([]T, 0, len(existing))
+	exists := make(map[string]bool)
+	for _, e := range existing {
+		exists[e.Key()] = true
+		if adopted[e.Key()] || o.owns(e, true) {
+			ownedExisting = append(ownedExisting, e)
+		}
+	}
+	ownedKeys := make(map[string]bool)
+	for _, e := range ownedExisting {
+		ownedKeys[e.Key()] = true
+	}
+
+	ownedDesired := make([]T, 0, len(desired))
+	for _, d := range desired {
+		owned := ownedKeys[d.Key()]
+		if !exists[d.Key()] {
+			owned = adopted[d.Key()] || o.owns(d, false)
+		}
+		if !owned {
+			log.WithFields(log.Fields{
+				"instance": instance,
+				"toplevel": toplevelName,
+				"name":     d.Key(),
+			}).Warn("entry is not owned by vault-manager and is skipped, set `adopt: true` to take its ownership")
+			continue
+		}
+		ownedDesired = append(ownedDesired, d)
+	}
+	return ownedDesired, ownedExisting
+}
@@ -0,0 +1,105 @@
+package toplevel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type ownedEntry struct {
+	name      string
+	managedBy string
+	adopt     bool
+}
+
+func (e ownedEntry) Key() string               { return e.name }
+func (e ownedEntry) Equals(i interface{}) bool { return e == i }
+func (e ownedEntry) KeyForDescription() string { return "" }
+func (e ownedEntry) KeyForType() string        { return "entry" }
+func (e ownedEntry) Adopts() bool              { return e.adopt }
+func (e ownedEntry) ManagedBy() string         { return e.managedBy }
+
+func keys(entries []ownedEntry) []string {
+	names := []string{}
+	for _, e := range entries {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+func TestParseOwnership(t *testing.T) {
+	t.Parallel()
+
+	ownerships, err := ParseOwnership("vault_budgeted=prefix:app-,vault_budgeted=glob:team/*,vault_budgeted=metadata")
+	require.NoError(t, err)
+	require.Equal(t, &Ownership{prefixes: []string{"app-"}, globs: []string{"team/*"}, marker: true},
+		ownerships["vault_budgeted"])
+
+	for rule, expectedErr := range map[string]string{
+		"vault_budgeted":             "invalid ownership rule \"vault_budgeted\", must be `TOPLEVEL=RULE`",
+		"vault_unknown=metadata":     "unknown top-level configuration `vault_unknown` of ownership rule \"vault_unknown=metadata\"",
+		"vault_budgeted=prefix:":     "invalid ownership rule \"vault_budgeted=prefix:\", must be `prefix:PREFIX`, `glob:PATTERN` or `metadata`",
+		"vault_budgeted=glob:[":      "invalid glob of ownership rule \"vault_budgeted=glob:[\": syntax error in pattern",
+		"vault_budgeted=owned-by-us": "invalid ownership rule \"vault_budgeted=owned-by-us\", must be `prefix:PREFIX`, `glob:PATTERN` or `metadata`",
+	} {
+		_, err := ParseOwnership(rule)
+		require.EqualError(t, err, expectedErr)
+	}
+}
+
+func TestOwnedByPrefix(t *testing.T) {
+	t.Parallel()
+
+	ownership := &Ownership{prefixes: []string{"app-"}}
+	desired := []ownedEntry{
+		{name: "app-written"},
+		{name: "app-updated"},
+		// created by another team
+		{name: "platform-conflict"},
+		{name: "platform-adopted", adopt: true},
+		// would not be owned once written
+		{name: "new-outside"},
+	}
+	existing := []ownedEntry{
+		{name: "app-updated"},
+		{name: "app-deleted"},
+		{name: "platform-conflict"},
+		{name: "platform-adopted"},
+		{name: "platform-ignored"},
+	}
+
+	ownedDesired, ownedExisting := ownedBy(ownership, "https://a", "vault_budgeted", desired, existing)
+	require.Equal(t, []string{"app-written", "app-updated", "platform-adopted"}, keys(ownedDesired))
+	require.Equal(t, []string{"app-updated", "app-deleted", "platform-adopted"}, keys(ownedExisting))
+}
+
+func TestOwnedByMetadata(t *testing.T) {
+	t.Parallel()
+
+	ownership := &Ownership{marker: true}
+	desired := []ownedEntry{
+		{name: "new", managedBy: ManagedByValue},
+		{name: "marked", managedBy: ManagedByValue},
+		{name: "unmarked", managedBy: ManagedByValue},
+	}
+	existing := []ownedEntry{
+		{name: "marked", managedBy: ManagedByValue},
+		{name: "unmarked"},
+		{name: "marked-deleted", managedBy: ManagedByValue},
+		{name: "unmarked-ignored", managedBy: "terraform"},
+	}
+
+	ownedDesired, ownedExisting := ownedBy(ownership, "https://a", "vault_budgeted", desired, existing)
+	require.Equal(t, []string{"new", "marked"}, keys(ownedDesired))
+	require.Equal(t, []string{"marked", "marked-deleted"}, keys(ownedExisting))
+}
+
+func TestOwnedWithoutRules(t *testing.T) {
+	t.Parallel()
+
+	desired := []ownedEntry{{name: "a"}}
+	existing := []ownedEntry{{name: "b"}}
+	ownedDesired, ownedExisting := Owned("https://a", "vault_unruled", desired, existing)
+	require.Equal(t, desired, ownedDesired)
+	require.Equal(t, existing, ownedExisting)
+}
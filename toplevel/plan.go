@@ -0,0 +1,187 @@
+package toplevel
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// Action describes the kind of change planned for a Vault item.
+type Action string
+
+const (
+	ActionWrite  Action = "write"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+)
+
+// Change is a single planned write, update or deletion of a Vault item.
+type Change struct {
+	Instance string                 `json:"instance"`
+	Toplevel string                 `json:"toplevel"`
+	Key      string                 `json:"key"`
+	Type     string                 `json:"type,omitempty"`
+	Action   Action                 `json:"action"`
+	Before   map[string]interface{} `json:"before,omitempty"`
+	After    map[string]interface{} `json:"after,omitempty"`
+	Diff     []string               `json:"diff,omitempty"`
+}
+
+// Describer is implemented by items that can report the fields recorded
+// as before/after state within a plan.
+type Describer interface {
+	Describe() map[string]interface{}
+}
+
+// Plan collects every change computed by the top-level configurations
+// across all instances during a single run.
+type Plan struct {
+	DryRun  bool     `json:"dry_run"`
+	Changes []Change `json:"changes"`
+
+	m       sync.Mutex
+	overlay *Overlay
+}
+
+// NewPlan returns an empty plan.
+func NewPlan(dryRun bool) *Plan {
+	p := &Plan{DryRun: dryRun, Changes: []Change{}}
+	if dryRun {
+		p.overlay = &Overlay{entries: make(map[string]map[ReferenceKind]map[string]Planned)}
+	}
+	return p
+}
+
+// Add records a single change. A nil plan silently discards changes.
+func (p *Plan) Add(c Change) {
+	if p == nil {
+		return
+	}
+	c.Before = normalizeFields(c.Before)
+	c.After = normalizeFields(c.After)
+	p.m.Lock()
+	defer p.m.Unlock()
+	p.Changes = append(p.Changes, c)
+}
+
+// AddItems records a change for each item. When an existing item shares the
+// key of a written or updated item, it is recorded as the before state.
+func (p *Plan) AddItems(instance, toplevelName string, action Action, items, existing []vault.Item) {
+	for _, item := range items {
+		c := Change{
+			Instance: instance,
+			Toplevel: toplevelName,
+			Key:      item.Key(),
+			Type:     item.KeyForType(),
+			Action:   action,
+		}
+		if action == ActionDelete {
+			c.Before = describe(item)
+		} else {
+			c.After = describe(item)
+			c.Diff = vault.DiffItem(item, existing)
+			for _, e := range existing {
+				if e.Key() == item.Key() {
+					c.Before = describe(e)
+					break
+				}
+			}
+		}
+		p.Add(c)
+	}
+}
+
+// Counts returns the number of changes planned for a top-level configuration of an
+// instance by action
+func (p *Plan) Counts(instance, toplevelName string) map[Action]int {
+	counts := make(map[Action]int)
+	if p == nil {
+		return counts
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	for _, c := range p.Changes {
+		if c.Instance == instance && c.Toplevel == toplevelName {
+			counts[c.Action]++
+		}
+	}
+	return counts
+}
+
+// order of actions within a written plan, as they are applied
+var actionOrder = map[Action]int{
+	ActionWrite:  0,
+	ActionUpdate: 1,
+	ActionDelete: 2,
+}
+
+// WriteFile marshals the plan as JSON into the file at path. Changes are sorted by
+// instance, top-level configuration, action and key, so that plans of runs computing
+// the same changes are identical although instances are reconciled concurrently.
+func (p *Plan) WriteFile(path string) error {
+	p.m.Lock()
+	defer p.m.Unlock()
+	sort.SliceStable(p.Changes, func(i, j int) bool {
+		x, y := p.Changes[i], p.Changes[j]
+		if x.Instance != y.Instance {
+			return x.Instance < y.Instance
+		}
+		if x.Toplevel != y.Toplevel {
+			return x.Toplevel < y.Toplevel
+		}
+		if x.Action != y.Action {
+			return actionOrder[x.Action] < actionOrder[y.Action]
+		}
+		return x.Key < y.Key
+	})
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func describe(item vault.Item) map[string]interface{} {
+	if d, ok := item.(Describer); ok {
+		return d.Describe()
+	}
+	return nil
+}
+
+func normalizeFields(fields map[string]interface{}) map[string]interface{} {
+	if fields == nil {
+		return nil
+	}
+	return normalize(fields).(map[string]interface{})
+}
+
+// normalize converts nested yaml maps into maps with string keys so that
+// recorded fields can be marshalled as JSON.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprintf("%v", k)] = normalize(val)
+		}
+		return m
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[k] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(t))
+		for i, val := range t {
+			s[i] = normalize(val)
+		}
+		return s
+	default:
+		return v
+	}
+}
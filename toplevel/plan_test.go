@@ -0,0 +1,115 @@
+package toplevel
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	name string
+	data interface{}
+}
+
+func (i item) Key() string {
+	return i.name
+}
+
+func (i item) KeyForType() string {
+	return "test"
+}
+
+func (i item) KeyForDescription() string {
+	return ""
+}
+
+func (i item) Equals(interface{}) bool {
+	return false
+}
+
+func (i item) Describe() map[string]interface{} {
+	return map[string]interface{}{"data": i.data}
+}
+
+func TestPlanAddItems(t *testing.T) {
+	plan := NewPlan(true)
+	existing := []vault.Item{item{"x", "old"}}
+
+	plan.AddItems("addr", "vault_test", ActionWrite, []vault.Item{item{"x", "new"}, item{"y", "new"}}, existing)
+	plan.AddItems("addr", "vault_test", ActionDelete, []vault.Item{item{"z", "gone"}}, nil)
+
+	require.Equal(t, []Change{
+		{
+			Instance: "addr", Toplevel: "vault_test", Key: "x", Type: "test", Action: ActionWrite,
+			Before: map[string]interface{}{"data": "old"},
+			After:  map[string]interface{}{"data": "new"},
+		},
+		{
+			Instance: "addr", Toplevel: "vault_test", Key: "y", Type: "test", Action: ActionWrite,
+			After: map[string]interface{}{"data": "new"},
+		},
+		{
+			Instance: "addr", Toplevel: "vault_test", Key: "z", Type: "test", Action: ActionDelete,
+			Before: map[string]interface{}{"data": "gone"},
+		},
+	}, plan.Changes)
+}
+
+func TestPlanCounts(t *testing.T) {
+	plan := NewPlan(false)
+	plan.AddItems("addr", "vault_test", ActionWrite, []vault.Item{item{"x", "new"}, item{"y", "new"}}, nil)
+	plan.AddItems("addr", "vault_test", ActionDelete, []vault.Item{item{"z", "gone"}}, nil)
+	plan.AddItems("other", "vault_test", ActionDelete, []vault.Item{item{"z", "gone"}}, nil)
+
+	require.Equal(t, map[Action]int{ActionWrite: 2, ActionDelete: 1}, plan.Counts("addr", "vault_test"))
+	require.Empty(t, plan.Counts("addr", "vault_other"))
+}
+
+func TestPlanWriteFile(t *testing.T) {
+	plan := NewPlan(false)
+	// nested yaml maps must be converted before they can be marshalled as JSON
+	plan.AddItems("addr", "vault_test", ActionWrite,
+		[]vault.Item{item{"x", map[interface{}]interface{}{"k": "v"}}}, nil)
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, plan.WriteFile(path))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	require.Equal(t, false, decoded["dry_run"])
+	change := decoded["changes"].([]interface{})[0].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"data": map[string]interface{}{"k": "v"}}, change["after"])
+}
+
+func TestPlanWriteFileSorted(t *testing.T) {
+	plan := NewPlan(false)
+	plan.AddItems("b", "vault_test", ActionWrite, []vault.Item{item{"x", nil}}, nil)
+	plan.AddItems("a", "vault_test", ActionDelete, []vault.Item{item{"x", nil}}, nil)
+	plan.AddItems("a", "vault_test", ActionWrite, []vault.Item{item{"y", nil}, item{"x", nil}}, nil)
+	plan.AddItems("a", "vault_other", ActionUpdate, []vault.Item{item{"z", nil}}, nil)
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	require.NoError(t, plan.WriteFile(path))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var decoded Plan
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	changes := []string{}
+	for _, c := range decoded.Changes {
+		changes = append(changes, c.Instance+" "+c.Toplevel+" "+string(c.Action)+" "+c.Key)
+	}
+	require.Equal(t, []string{
+		"a vault_other update z",
+		"a vault_test write x",
+		"a vault_test write y",
+		"a vault_test delete x",
+		"b vault_test write x",
+	}, changes)
+}
@@ -3,6 +3,8 @@
 package policy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -24,11 +26,13 @@ func init() {
 }
 
 type entry struct {
-	Name        string         `yaml:"name"`
-	Rules       string         `yaml:"rules"`
-	Type        string         `yaml:"type"`
-	Instance    vault.Instance `yaml:"instance"`
-	Description string         `yaml:"description"`
+	Name        string             `yaml:"name"`
+	Rules       string             `yaml:"rules"`
+	Type        string             `yaml:"type"`
+	Instance    vault.Instance     `yaml:"instance"`
+	Description string             `yaml:"description"`
+	Adopt       bool               `yaml:"adopt"`
+	Lifecycle   toplevel.Lifecycle `yaml:"lifecycle"`
 }
 
 var _ vault.Item = entry{}
@@ -45,6 +49,26 @@ func (e entry) KeyForDescription() string {
 	return e.Description
 }
 
+// Adopts returns whether the entry takes the ownership of an existing entry that is
+// not owned by vault-manager
+func (e entry) Adopts() bool {
+	return e.Adopt
+}
+
+// PreventsDestroy returns whether the entry is declared with `prevent_destroy`
+func (e entry) PreventsDestroy() bool {
+	return e.Lifecycle.PreventDestroy
+}
+
+// Retain returns the entry where the rules take the value of the existing policy when
+// ignored by its lifecycle
+func (e entry) Retain(existing entry) entry {
+	if e.Lifecycle.Ignores("rules") {
+		e.Rules = existing.Rules
+	}
+	return e
+}
+
 func (e entry) Equals(i interface{}) bool {
 	entry, ok := i.(entry)
 	if !ok {
@@ -54,12 +78,29 @@ func (e entry) Equals(i interface{}) bool {
 	return e.Name == entry.Name && e.Rules == entry.Rules
 }
 
+func (e entry) Diff(i interface{}) []string {
+	existing, ok := i.(entry)
+	if !ok {
+		return nil
+	}
+	return vault.DiffLines(existing.Rules, e.Rules)
+}
+
+func (e entry) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"name":  e.Name,
+		"rules": e.Rules,
+	}
+}
+
 // TODO(dwelch): refactor into multiple functions
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
+// Apply writes policies in the write phase and deletes them in the delete phase,
+// once no role or group references them anymore
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
 	// Unmarshal the list of configured secrets engines.
 	var entries []entry
 	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
-		log.WithError(err).Fatal("[Vault Policy] failed to decode policies configuration")
+		return vault.NewConfigError("[Vault Policy] failed to decode policies configuration", err)
 	}
 	instancesToDesiredPolicies := make(map[string][]entry)
 	for _, e := range entries {
@@ -70,10 +111,10 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		func(e entry) string {
 			return e.Key()
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
-	existingPolicyNames, err := vault.ListVaultPolicies(address)
+	existingPolicyNames, err := vault.ListVaultPolicies(ctx, address)
 	if err != nil {
 		return err
 	}
@@ -82,7 +123,8 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 	existingPolicies := []entry{}
 	var mutex = &sync.Mutex{}
 	bwg := utils.NewBoundedWaitGroup(threadPoolSize)
-	ch := make(chan error)
+	// buffered so that no goroutine blocks once the first error is returned
+	ch := make(chan error, len(existingPolicyNames))
 
 	// fill existing policies array in parallel
 	for i := range existingPolicyNames {
@@ -92,7 +134,7 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 			defer bwg.Done()
 
 			name := existingPolicyNames[i]
-			policy, err := vault.GetVaultPolicy(address, name)
+			policy, err := vault.GetVaultPolicy(ctx, address, name)
 			if err != nil {
 				ch <- err
 				return
@@ -104,54 +146,76 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		}(i, ch)
 	}
 
-	go func() {
-		bwg.Wait()
-		close(ch)
-	}()
+	bwg.Wait()
+	close(ch)
 
+	// only the first error is reported
 	for e := range ch {
 		if e != nil {
 			return e
 		}
 	}
 
+	// policies that are not owned by vault-manager are left untouched
+	desiredPolicies, existingPolicies := toplevel.Owned(address, toplevelName, instancesToDesiredPolicies[address], existingPolicies)
+	desiredPolicies = toplevel.IgnoreChanges(desiredPolicies, existingPolicies)
+
 	// Diff the local configuration with the Vault instance.
 	toBeWritten, toBeDeleted, _ :=
-		vault.DiffItems(asItems(instancesToDesiredPolicies[address]), asItems(existingPolicies))
+		vault.DiffItems(asItems(desiredPolicies), asItems(existingPolicies))
+	toBeDeleted = withoutDefaultPolicies(toBeDeleted)
+	err = toplevel.CheckDeletions(address, toplevelName, len(toBeDeleted), len(withoutDefaultPolicies(asItems(existingPolicies))))
+	if err != nil {
+		return err
+	}
+	err = toplevel.CheckDestroys(address, toplevelName, desiredPolicies, toBeDeleted)
+	if err != nil {
+		return err
+	}
+	// groups affected by policy changes are reported during a dry run, including by
+	// policies deleted in the delete phase, which is applied to groups first
+	plan.Overlay().RecordItems(toplevel.PolicyRef, address, toplevel.ActionWrite, toBeWritten)
+	plan.Overlay().RecordItems(toplevel.PolicyRef, address, toplevel.ActionDelete, toBeDeleted)
+
+	if phase == toplevel.PhaseWrite {
+		plan.AddItems(address, toplevelName, toplevel.ActionWrite, toBeWritten, asItems(existingPolicies))
+		return writePolicies(ctx, address, toBeWritten, existingPolicies, dryRun)
+	}
+	plan.AddItems(address, toplevelName, toplevel.ActionDelete, toBeDeleted, nil)
+	return deletePolicies(ctx, address, toBeDeleted, dryRun)
+}
 
-	if dryRun == true {
-		for _, w := range toBeWritten {
+// Write any missing policies to the Vault instance.
+func writePolicies(ctx context.Context, address string, toBeWritten []vault.Item, existing []entry, dryRun bool) error {
+	for _, w := range toBeWritten {
+		if dryRun == true {
 			log.WithField("instance", address).Infof("[Dry Run] [Vault Policy] policy to be written='%v'", w.Key())
-		}
-		for _, d := range toBeDeleted {
-			if isDefaultPolicy(d.Key()) {
-				continue
-			}
-			log.WithField("instance", address).Infof("[Dry Run] [Vault Policy] policy to be deleted='%v'", d.Key())
-		}
-		toplevel.UpdatePolicies(toBeWritten, toBeDeleted)
-	} else {
-		// Write any missing policies to the Vault instance.
-		for _, e := range toBeWritten {
-			ent := e.(entry)
-			err := vault.PutVaultPolicy(address, ent.Name, ent.Rules)
-			if err != nil {
-				return err
+			for _, line := range vault.DiffItem(w, asItems(existing)) {
+				log.WithField("instance", address).Infof("[Dry Run] [Vault Policy] policy='%v' diff: %s", w.Key(), line)
 			}
+			continue
 		}
-		// Delete any policies from the Vault instance.
-		for _, e := range toBeDeleted {
-			ent := e.(entry)
-			if isDefaultPolicy(ent.Name) {
-				continue
-			}
-			err := vault.DeleteVaultPolicy(address, ent.Name)
-			if err != nil {
-				return err
-			}
+		ent := w.(entry)
+		err := vault.PutVaultPolicy(ctx, address, ent.Name, ent.Rules)
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
+// Delete any policies from the Vault instance.
+func deletePolicies(ctx context.Context, address string, toBeDeleted []vault.Item, dryRun bool) error {
+	for _, d := range toBeDeleted {
+		if dryRun == true {
+			log.WithField("instance", address).Infof("[Dry Run] [Vault Policy] policy to be deleted='%v'", d.Key())
+			continue
+		}
+		err := vault.DeleteVaultPolicy(ctx, address, d.(entry).Name)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -159,6 +223,16 @@ func isDefaultPolicy(name string) bool {
 	return name == "root" || name == "default"
 }
 
+func withoutDefaultPolicies(items []vault.Item) []vault.Item {
+	filtered := make([]vault.Item, 0, len(items))
+	for _, item := range items {
+		if !isDefaultPolicy(item.Key()) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
 func asItems(xs []entry) (items []vault.Item) {
 	items = make([]vault.Item, 0)
 	for _, x := range xs {
@@ -0,0 +1,32 @@
+package policy
+
+import (
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks required fields and the uniqueness of policy names per instance.
+// Every policy is declared so that references from other configurations can be checked.
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries []entry
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+	refs.Manages(toplevel.PolicyRef)
+
+	problems := []toplevel.Problem{}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		p := toplevel.Problem{Toplevel: toplevelName, Instance: e.Instance.Address, Entry: e.Name}
+		problems = append(problems, p.Require("name", e.Name)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		if seen[e.Instance.Address+e.Name] {
+			problems = append(problems, p.Withf("duplicate policy name"))
+		}
+		seen[e.Instance.Address+e.Name] = true
+		refs.Declare(toplevel.PolicyRef, e.Instance.Address, e.Name)
+	}
+	return problems
+}
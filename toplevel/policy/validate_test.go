@@ -0,0 +1,101 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		policies    string
+		expected    []string
+	}{
+		{
+			"valid policy",
+			`
+- name: app
+  rules: path "secret/*" { capabilities = ["read"] }
+  instance:
+    address: https://vault
+`,
+			[]string{},
+		},
+		{
+			"missing name",
+			`
+- rules: path "secret/*" { capabilities = ["read"] }
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `name`"},
+		},
+		{
+			"missing instance address",
+			`
+- name: app
+  rules: path "secret/*" { capabilities = ["read"] }
+`,
+			[]string{"missing required field `instance.address`"},
+		},
+		{
+			"duplicate name",
+			`
+- name: app
+  instance:
+    address: https://vault
+- name: app
+  instance:
+    address: https://vault
+`,
+			[]string{"duplicate policy name"},
+		},
+		{
+			"same name on different instances",
+			`
+- name: app
+  instance:
+    address: https://vault
+- name: app
+  instance:
+    address: https://other
+`,
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			problems := config{}.Validate([]byte(c.policies), toplevel.NewReferences())
+			messages := []string{}
+			for _, p := range problems {
+				messages = append(messages, p.Message)
+			}
+			require.Equal(t, c.expected, messages)
+		})
+	}
+}
+
+func TestValidateDeclaresPolicies(t *testing.T) {
+	t.Parallel()
+
+	refs := toplevel.NewReferences()
+	require.Empty(t, config{}.Validate([]byte(`
+- name: app
+  instance:
+    address: https://vault
+`), refs))
+	refs.Reference(toplevel.PolicyRef, "app", toplevel.Problem{Instance: "https://vault", Entry: "declared"})
+	refs.Reference(toplevel.PolicyRef, "app", toplevel.Problem{Instance: "https://other", Entry: "dangling"})
+
+	dangling := refs.Dangling()
+	require.Len(t, dangling, 1)
+	require.Equal(t, "dangling", dangling[0].Entry)
+	require.Equal(t, "references policy `app` which is not declared for the instance", dangling[0].Message)
+}
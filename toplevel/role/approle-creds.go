@@ -1,19 +1,30 @@
 package role
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
 
 	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
 	log "github.com/sirupsen/logrus"
 )
 
-func populateApproleCreds(address string, roles []entry, dryRun bool) error {
-	kvVersions, err := getKvEngineVersions(address)
+func populateApproleCreds(ctx context.Context, address string, roles []entry, dryRun bool, plan *toplevel.Plan) error {
+	kvVersions, err := getKvEngineVersions(ctx, address)
 	if err != nil {
 		return err
 	}
+	// kv engines planned by the secrets engines configuration are not enabled during a dry run
+	plannedEngines := plannedKvEngineVersions(plan.Overlay().Written(toplevel.SecretEngineRef, address))
+	for name, version := range plannedEngines {
+		if _, exists := kvVersions[name]; !exists {
+			kvVersions[name] = version
+		} else {
+			delete(plannedEngines, name)
+		}
+	}
 
 	for _, role := range roles {
 		if strings.ToLower(role.Type) == "approle" && len(role.OutputPath) > 0 {
@@ -45,7 +56,11 @@ func populateApproleCreds(address string, roles []entry, dryRun bool) error {
 				}).Info("[Vault Approle] Retrieved KV version is not supported")
 				return errors.New("approle creds unsupported KV version")
 			}
-			secret, err := vault.ReadSecret(address, role.OutputPath, version)
+			// nothing is written within a kv engine only planned to be enabled
+			var secret map[string]interface{}
+			if _, planned := plannedEngines[fmt.Sprint(pathRoot, "/")]; !planned {
+				secret, err = vault.ReadSecret(ctx, address, role.OutputPath, version)
+			}
 			if err != nil {
 				log.WithFields(log.Fields{
 					"name":       role.Name,
@@ -59,6 +74,18 @@ func populateApproleCreds(address string, roles []entry, dryRun bool) error {
 				continue
 			}
 
+			plan.Add(toplevel.Change{
+				Instance: address,
+				Toplevel: toplevelName,
+				Key:      role.OutputPath,
+				Type:     "approle-creds",
+				Action:   toplevel.ActionWrite,
+				After: map[string]interface{}{
+					"role": role.Name,
+					"path": role.OutputPath,
+				},
+			})
+
 			if dryRun {
 				log.WithFields(log.Fields{
 					"name":       role.Name,
@@ -67,12 +94,12 @@ func populateApproleCreds(address string, roles []entry, dryRun bool) error {
 					"instance":   address,
 				}).Info("[DRY RUN][Vault Approle] Credentials written to desired path")
 			} else {
-				creds, err := generatePayload(address, role)
+				creds, err := generatePayload(ctx, address, role)
 				if err != nil {
 					return err
 				}
 				// write creds to desired output
-				err = vault.WriteSecret(address, role.OutputPath, version, creds)
+				err = vault.WriteSecret(ctx, address, role.OutputPath, version, creds)
 				if err != nil {
 					return err
 				}
@@ -90,8 +117,8 @@ func populateApproleCreds(address string, roles []entry, dryRun bool) error {
 
 // Returns map of kv engine names to their kv versions
 // KV v1 and v2 require different path formats for rw
-func getKvEngineVersions(address string) (map[string]string, error) {
-	secretEngines, err := vault.ListSecretsEngines(address)
+func getKvEngineVersions(ctx context.Context, address string) (map[string]string, error) {
+	secretEngines, err := vault.ListSecretsEngines(ctx, address)
 	if err != nil {
 		return nil, err
 	}
@@ -114,10 +141,27 @@ func getKvEngineVersions(address string) (map[string]string, error) {
 	return kvVersions, nil
 }
 
+// Returns map of names of kv engines planned to be enabled to their kv versions
+func plannedKvEngineVersions(planned map[string]toplevel.Planned) map[string]string {
+	kvVersions := make(map[string]string)
+	for path, engine := range planned {
+		if engine.Type != "kv" {
+			continue
+		}
+		// kv engines enabled without a version are kv v1
+		version := engine.Options["version"]
+		if version == "" {
+			version = "1"
+		}
+		kvVersions[strings.Trim(path, "/")+"/"] = version
+	}
+	return kvVersions
+}
+
 // returns a map containing the role_id, secret_id, and secret_id_accessor for an approle
-func generatePayload(address string, role entry) (map[string]interface{}, error) {
+func generatePayload(ctx context.Context, address string, role entry) (map[string]interface{}, error) {
 	creds := make(map[string]interface{})
-	roleSecret, err := vault.ReadSecret(
+	roleSecret, err := vault.ReadSecret(ctx,
 		address,
 		fmt.Sprintf("auth/approle/role/%s/role-id", role.Name),
 		vault.KV_V1, // vault internally stored approle data within KV v1
@@ -133,7 +177,7 @@ func generatePayload(address string, role entry) (map[string]interface{}, error)
 		return nil, errors.New("role_id retrieval failed")
 	}
 	creds["role_id"] = roleSecret["role_id"]
-	secretIdResult, err := vault.GenerateApproleSecretID(
+	secretIdResult, err := vault.GenerateApproleSecretID(ctx,
 		address,
 		fmt.Sprintf("auth/approle/role/%s/secret-id", role.Name),
 	)
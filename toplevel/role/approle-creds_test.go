@@ -0,0 +1,19 @@
+package role
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlannedKvEngineVersions(t *testing.T) {
+	t.Parallel()
+
+	versions := plannedKvEngineVersions(map[string]toplevel.Planned{
+		"app-interface": {Action: toplevel.ActionWrite, Type: "kv", Options: map[string]string{"version": "2"}},
+		"legacy/":       {Action: toplevel.ActionWrite, Type: "kv"},
+		"pki/":          {Action: toplevel.ActionWrite, Type: "pki"},
+	})
+	require.Equal(t, map[string]string{"app-interface/": "2", "legacy/": "1"}, versions)
+}
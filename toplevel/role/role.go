@@ -3,6 +3,8 @@
 package role
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"strings"
@@ -12,6 +14,7 @@ import (
 	"github.com/app-sre/vault-manager/pkg/vault"
 	"github.com/app-sre/vault-manager/toplevel"
 	"github.com/hashicorp/go-version"
+	"github.com/hashicorp/vault/api"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v2"
 )
@@ -24,6 +27,8 @@ type entry struct {
 	OutputPath  string                 `yaml:"output_path"`
 	Options     map[string]interface{} `yaml:"options"`
 	Description string                 `yaml:"description"`
+	Adopt       bool                   `yaml:"adopt"`
+	Lifecycle   toplevel.Lifecycle     `yaml:"lifecycle"`
 }
 
 type authMount struct {
@@ -47,6 +52,29 @@ func (e entry) KeyForDescription() string {
 	return e.Description
 }
 
+// Adopts returns whether the entry takes the ownership of an existing entry that is
+// not owned by vault-manager
+func (e entry) Adopts() bool {
+	return e.Adopt
+}
+
+// OwnershipPath returns the path of the role within auth backends, ex: `approle/name`
+func (e entry) OwnershipPath() string {
+	return strings.Trim(e.Mount.Path, "/") + "/" + e.Name
+}
+
+// PreventsDestroy returns whether the entry is declared with `prevent_destroy`
+func (e entry) PreventsDestroy() bool {
+	return e.Lifecycle.PreventDestroy
+}
+
+// Retain returns the entry where the options ignored by its lifecycle take the value of
+// the existing role
+func (e entry) Retain(existing entry) entry {
+	e.Options = toplevel.Retain(e.Lifecycle, e.Options, existing.Options)
+	return e
+}
+
 func (e entry) Equals(i interface{}) bool {
 	entry, ok := i.(entry)
 	if !ok {
@@ -59,7 +87,26 @@ func (e entry) Equals(i interface{}) bool {
 		vault.OptionsEqual(e.Options, entry.Options)
 }
 
-func (e entry) Save() error {
+func (e entry) Diff(i interface{}) []string {
+	existing, ok := i.(entry)
+	if !ok {
+		return nil
+	}
+	diff := vault.DiffField("type", existing.Type, e.Type)
+	diff = append(diff, vault.DiffField("mount", existing.Mount.Path, e.Mount.Path)...)
+	return append(diff, vault.DiffOptions(existing.Options, e.Options)...)
+}
+
+func (e entry) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"name":    e.Name,
+		"type":    e.Type,
+		"mount":   e.Mount.Path,
+		"options": e.Options,
+	}
+}
+
+func (e entry) Save(ctx context.Context) error {
 	path := filepath.Join("auth", e.Mount.Path, "role", e.Name)
 	options := make(map[string]interface{})
 	for k, v := range e.Options {
@@ -71,7 +118,7 @@ func (e entry) Save() error {
 			options[k] = v
 		}
 	}
-	err := vault.WriteSecret(e.Instance.Address, path, vault.KV_V1, options)
+	err := vault.WriteSecret(ctx, e.Instance.Address, path, vault.KV_V1, options)
 	if err != nil {
 		return err
 	}
@@ -83,11 +130,11 @@ func (e entry) Save() error {
 	return nil
 }
 
-func (e entry) Delete() error {
+func (e entry) Delete(ctx context.Context) error {
 	path := filepath.Join("auth", e.Mount.Path, "role", e.Name)
-	err := vault.DeleteSecret(e.Instance.Address, path)
+	err := vault.DeleteSecret(ctx, e.Instance.Address, path)
 	if err != nil {
-		return nil
+		return err
 	}
 	log.WithFields(log.Fields{
 		"path":     path,
@@ -107,11 +154,12 @@ func init() {
 
 // TODO(dwelch): refactor this into multiple functions
 // Apply ensures that an instance of Vault's roles are configured exactly
-// as provided.
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
+// as provided. Roles and approle credentials are written in the write phase and
+// roles are deleted in the delete phase.
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
 	var entries []entry
 	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
-		log.WithError(err).Fatal("[Vault Role] failed to decode role configuration")
+		return vault.NewConfigError("[Vault Role] failed to decode role configuration", err)
 	}
 	instancesToDesiredRoles := make(map[string][]entry)
 	for _, e := range entries {
@@ -119,7 +167,7 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 	}
 
 	desiredRoles := instancesToDesiredRoles[address]
-	existingAuths, err := vault.ListAuthBackends(address)
+	existingAuths, err := vault.ListAuthBackends(ctx, address)
 	if err != nil {
 		return err
 	}
@@ -128,7 +176,7 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		func(e entry) string {
 			return fmt.Sprintf("%s%s", e.Mount, e.Name)
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
 	if err := formatPolicyRefs(desiredRoles); err != nil {
@@ -143,7 +191,7 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 	for authBackend := range existingAuths {
 		// Get the secret with the existing App Roles.
 		path := filepath.Join("auth", authBackend, "role")
-		secret, err := vault.ListSecrets(address, path)
+		secret, err := vault.ListSecrets(ctx, address, path)
 		if err != nil {
 			return err
 		}
@@ -151,6 +199,7 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 			roles := secret.Data["keys"].([]interface{})
 
 			var mutex = &sync.Mutex{}
+			var readErr error
 			bwg := utils.NewBoundedWaitGroup(threadPoolSize)
 
 			// Fill existing policies array in parallel
@@ -164,10 +213,14 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 					mutex.Lock()
 					defer mutex.Unlock()
 
-					opts, err := vault.ReadSecret(address, path, vault.KV_V1)
+					opts, err := vault.ReadSecret(ctx, address, path, vault.KV_V1)
 					if err != nil {
 						// Reading of existing policies config failed
-						log.WithError(err).Fatal()
+						// only the first error is reported
+						if readErr == nil {
+							readErr = err
+						}
+						return
 					}
 					existingRoles = append(existingRoles,
 						entry{
@@ -180,10 +233,13 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 				}(i)
 			}
 			bwg.Wait()
+			if readErr != nil {
+				return readErr
+			}
 		}
 	}
 
-	addOptionalOidcDefaults(address, desiredRoles)
+	addOptionalOidcDefaults(ctx, address, desiredRoles)
 
 	err = unmarshallOptionObjects(desiredRoles)
 	if err != nil {
@@ -193,67 +249,110 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		return err
 	}
 
+	// roles that are not owned by vault-manager are left untouched
+	desiredRoles, existingRoles = toplevel.Owned(address, toplevelName, desiredRoles, existingRoles)
+	desiredRoles = toplevel.IgnoreChanges(desiredRoles, existingRoles)
+
 	// Diff the desired configuration with the Vault instance.
 	entriesToBeWritten, entriesToBeDeleted, _ :=
 		vault.DiffItems(asItems(desiredRoles), asItems(existingRoles))
+	err = toplevel.CheckDeletions(address, toplevelName, len(entriesToBeDeleted), len(existingRoles))
+	if err != nil {
+		return err
+	}
+	err = toplevel.CheckDestroys(address, toplevelName, desiredRoles, entriesToBeDeleted)
+	if err != nil {
+		return err
+	}
+
+	if phase == toplevel.PhaseDelete {
+		plan.AddItems(address, toplevelName, toplevel.ActionDelete, entriesToBeDeleted, nil)
+		return deleteRoles(ctx, address, entriesToBeDeleted, dryRun)
+	}
+
+	plan.AddItems(address, toplevelName, toplevel.ActionWrite, entriesToBeWritten, asItems(existingRoles))
 
 	if dryRun == true {
+		// auth backends planned by the auth backends configuration are not enabled during a dry run
+		plannedAuths := plan.Overlay().Written(toplevel.AuthBackendRef, address)
 		for _, w := range entriesToBeWritten {
+			if mount := w.(entry).Mount.Path; !authBackendExists(mount, existingAuths, plannedAuths) {
+				return fmt.Errorf("[Vault Role] auth backend `%s` of role `%s` is not enabled", mount, w.Key())
+			}
 			log.WithField("name", w.Key()).WithField("type", w.(entry).Type).WithField("instance", address).Info(
 				"[Dry Run] [Vault Role] role to be written")
-		}
-		for _, d := range entriesToBeDeleted {
-			log.WithField("name", d.Key()).WithField("type", d.(entry).Type).WithField("instance", address).Info(
-				"[Dry Run] [Vault Role] role to be deleted")
+			for _, line := range vault.DiffItem(w, asItems(existingRoles)) {
+				log.WithField("name", w.Key()).WithField("type", w.(entry).Type).WithField("instance", address).Infof(
+					"[Dry Run] [Vault Role] role diff: %s", line)
+			}
 		}
 	} else {
 		// Write any missing roles to the Vault instance.
 		for _, e := range entriesToBeWritten {
-			err := e.(entry).Save()
+			err := e.(entry).Save(ctx)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		// Delete any roles from the Vault instance.
-		for _, e := range entriesToBeDeleted {
-			err := e.(entry).Delete()
-			if err != nil {
-				return err
-			}
+	return populateApproleCreds(ctx, address, desiredRoles, dryRun, plan)
+}
+
+// authBackendExists returns whether the auth backend at path is enabled or planned to be enabled
+func authBackendExists(path string, existing map[string]*api.AuthMount, planned map[string]toplevel.Planned) bool {
+	for p := range existing {
+		if vault.EqualPathNames(p, path) {
+			return true
 		}
 	}
-
-	err = populateApproleCreds(address, desiredRoles, dryRun)
-	if err != nil {
-		return err
+	for p := range planned {
+		if vault.EqualPathNames(p, path) {
+			return true
+		}
 	}
+	return false
+}
 
+// Delete any roles from the Vault instance.
+func deleteRoles(ctx context.Context, address string, toBeDeleted []vault.Item, dryRun bool) error {
+	for _, d := range toBeDeleted {
+		if dryRun == true {
+			log.WithField("name", d.Key()).WithField("type", d.(entry).Type).WithField("instance", address).Info(
+				"[Dry Run] [Vault Role] role to be deleted")
+			continue
+		}
+		err := d.(entry).Delete(ctx)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// defines applicable properties(s) referencing policies for each role type
+// see https://github.com/app-sre/qontract-schemas/blob/main/schemas/vault-config/role-1.yml
+var policyProperties = map[string][]string{
+	"approle": {
+		"token_policies",
+		"policies",
+	},
+	"oidc": {
+		"token_policies",
+	},
+	"kubernetes": {
+		"token_policies",
+	},
+}
+
 // Extracts names of policies referenced within applicable properties of desired roles
 // and updates those properties to only include the names of policies.
 // This is necessary to support policy file references within schemas.
 // The graphql query will return nested object for policy properties but vault api
 // expects only names of policies to be included
 func formatPolicyRefs(desiredRoles []entry) error {
-	// defines applicable properties(s) for each role type to reformat
-	// see https://github.com/app-sre/qontract-schemas/blob/main/schemas/vault-config/role-1.yml
-	typeToProperties := map[string][]string{
-		"approle": {
-			"token_policies",
-			"policies",
-		},
-		"oidc": {
-			"token_policies",
-		},
-		"kubernetes": {
-			"token_policies",
-		},
-	}
 	for _, role := range desiredRoles {
-		for _, property := range typeToProperties[role.Type] {
+		for _, property := range policyProperties[role.Type] {
 			extracted := []string{}
 			policies, ok := role.Options[property].([]interface{})
 			if !ok {
@@ -322,7 +421,7 @@ func unmarshallOptionObjects(roles []entry) error {
 
 // addOptionalOidcDefaults adds optional attributes and corresponding default values to desired oidc roles
 // this circumvents defining every attribute within desired oidc roles
-func addOptionalOidcDefaults(instance string, roles []entry) {
+func addOptionalOidcDefaults(ctx context.Context, instance string, roles []entry) {
 	defaults := map[string]interface{}{
 		"bound_audiences":      []string{},
 		"bound_claims":         nil,
@@ -337,7 +436,7 @@ func addOptionalOidcDefaults(instance string, roles []entry) {
 		"oidc_scopes":          []string{},
 		"verbose_oidc_logging": false,
 	}
-	ver, err := vault.GetVaultVersion(instance)
+	ver, err := vault.GetVaultVersion(ctx, instance)
 	if err != nil {
 		log.WithField("instance", instance).Info(
 			"[Vault Role] unable to retrieve instance version")
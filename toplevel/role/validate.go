@@ -0,0 +1,78 @@
+package role
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/utils"
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks required fields, the policies referenced by each role, durations
+// within options and the json objects of oidc options. The auth backend of each
+// role and the kv engine of approle output paths are referenced so that they can be
+// checked against declared ones.
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries []entry
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+
+	problems := []toplevel.Problem{}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		p := toplevel.Problem{Toplevel: toplevelName, Instance: e.Instance.Address, Entry: e.Name}
+		problems = append(problems, p.Require("name", e.Name)...)
+		problems = append(problems, p.Require("type", e.Type)...)
+		problems = append(problems, p.Require("mount._path", e.Mount.Path)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		key := fmt.Sprintf("%s%s%s", e.Instance.Address, strings.Trim(e.Mount.Path, "/"), e.Name)
+		if seen[key] {
+			problems = append(problems, p.Withf("duplicate role name within mount `%s`", e.Mount.Path))
+		}
+		seen[key] = true
+		if e.Mount.Path != "" {
+			refs.Reference(toplevel.AuthBackendRef, e.Mount.Path, p)
+		}
+		// credentials of approles are written to the output path
+		if strings.ToLower(e.Type) == "approle" && e.OutputPath != "" {
+			refs.ReferenceWithin(toplevel.KVEngineRef, e.OutputPath, p)
+		}
+
+		for _, property := range policyProperties[e.Type] {
+			policies, ok := e.Options[property].([]interface{})
+			if !ok {
+				problems = append(problems, p.Withf("option `%s` must be a list of policies", property))
+				continue
+			}
+			for _, policy := range policies {
+				policyMap, ok := policy.(map[interface{}]interface{})
+				if !ok {
+					problems = append(problems, p.Withf("option `%s` must be a list of policies", property))
+					continue
+				}
+				name, ok := policyMap["name"].(string)
+				if !ok || name == "" {
+					problems = append(problems, p.Withf("policy without `name` within option `%s`", property))
+					continue
+				}
+				refs.Reference(toplevel.PolicyRef, name, p)
+			}
+		}
+
+		for _, msg := range toplevel.InvalidDurations(e.Options) {
+			problems = append(problems, p.Withf("options: %s", msg))
+		}
+		if strings.ToLower(e.Type) == "oidc" {
+			for _, k := range []string{"bound_claims", "claim_mappings"} {
+				if _, err := utils.UnmarshalJsonObj(k, e.Options[k]); err != nil {
+					problems = append(problems, p.Withf("option `%s` must be a json object: %v", k, err))
+				}
+			}
+		}
+	}
+	return problems
+}
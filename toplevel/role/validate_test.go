@@ -0,0 +1,68 @@
+package role
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	roles := []byte(`
+- name: valid
+  type: approle
+  mount:
+    _path: approle/
+  output_path: app/creds
+  instance:
+    address: https://vault
+  options:
+    token_ttl: 1h
+    token_policies:
+    - name: declared
+    policies: []
+- name: invalid
+  type: oidc
+  mount:
+    _path: oidc/
+  instance:
+    address: https://vault
+  options:
+    token_ttl: ""
+    token_policies: declared
+    bound_claims: '{"groups": '
+- name: invalid
+  type: approle
+  mount:
+    _path: approle/
+  output_path: other/creds
+  instance:
+    address: https://vault
+  options:
+    token_policies: []
+    policies: []
+`)
+	refs := toplevel.NewReferences()
+	refs.Manages(toplevel.PolicyRef)
+	refs.Manages(toplevel.AuthBackendRef)
+	refs.Manages(toplevel.KVEngineRef)
+	refs.Declare(toplevel.PolicyRef, "https://vault", "declared")
+	refs.Declare(toplevel.AuthBackendRef, "https://vault", "approle")
+	refs.Declare(toplevel.KVEngineRef, "https://vault", "app/")
+
+	problems := config{}.Validate(roles, refs)
+	messages := []string{}
+	for _, p := range append(problems, refs.Dangling()...) {
+		require.Equal(t, "invalid", p.Entry)
+		messages = append(messages, p.Message)
+	}
+	require.Equal(t, []string{
+		"option `token_policies` must be a list of policies",
+		"options: invalid duration `` of `token_ttl`: empty duration",
+		"option `bound_claims` must be a json object: unexpected end of JSON input",
+		"references auth backend `oidc/` which is not declared for the instance",
+		"references `other/creds` which is not within a kv secrets engine declared for the instance",
+	}, messages)
+}
@@ -5,7 +5,10 @@
 package secretsengine
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/hashicorp/vault/api"
@@ -18,13 +21,20 @@ import (
 )
 
 type entry struct {
-	Path        string            `yaml:"_path"`
-	Type        string            `yaml:"type"`
-	Instance    vault.Instance    `yaml:"instance"`
-	Description string            `yaml:"description"`
-	Options     map[string]string `yaml:"options"`
+	Path        string                                `yaml:"_path"`
+	Type        string                                `yaml:"type"`
+	Instance    vault.Instance                        `yaml:"instance"`
+	Description string                                `yaml:"description"`
+	Options     map[string]string                     `yaml:"options"`
+	Tune        map[string]interface{}                `yaml:"tune"`
+	Settings    utils.JSONMap[map[string]interface{}] `yaml:"settings"`
+	Adopt       bool                                  `yaml:"adopt"`
+	Lifecycle   toplevel.Lifecycle                    `yaml:"lifecycle"`
 }
 
+// kv v2 keeps max_versions within <path>/config rather than the mount tune endpoint
+const maxVersions = "max_versions"
+
 var _ vault.Item = entry{}
 
 const toplevelName = "vault_secret_engines"
@@ -33,6 +43,28 @@ func (e entry) Key() string {
 	return e.Path
 }
 
+// Adopts returns whether the entry takes the ownership of an existing entry that is
+// not owned by vault-manager
+func (e entry) Adopts() bool {
+	return e.Adopt
+}
+
+// PreventsDestroy returns whether the entry is declared with `prevent_destroy`
+func (e entry) PreventsDestroy() bool {
+	return e.Lifecycle.PreventDestroy
+}
+
+// Retain returns the entry where the description, options and tune attributes ignored
+// by its lifecycle take the value of the existing secrets engine
+func (e entry) Retain(existing entry) entry {
+	if e.Lifecycle.Ignores("description") {
+		e.Description = existing.Description
+	}
+	e.Options = toplevel.Retain(e.Lifecycle, e.Options, existing.Options)
+	e.Tune = toplevel.Retain(e.Lifecycle, e.Tune, existing.Tune)
+	return e
+}
+
 func (e entry) Equals(i interface{}) bool {
 	entry, ok := i.(entry)
 	if !ok {
@@ -42,7 +74,8 @@ func (e entry) Equals(i interface{}) bool {
 	return vault.EqualPathNames(e.Path, entry.Path) &&
 		e.Type == entry.Type &&
 		e.Description == entry.Description &&
-		vault.OptionsEqual(e.ambiguousOptions(), entry.ambiguousOptions())
+		vault.OptionsEqual(e.ambiguousOptions(), entry.ambiguousOptions()) &&
+		vault.OptionsEqual(e.Tune, entry.Tune)
 }
 
 func (e entry) KeyForDescription() string {
@@ -53,6 +86,42 @@ func (e entry) KeyForType() string {
 	return e.Type
 }
 
+func (e entry) Diff(i interface{}) []string {
+	existing, ok := i.(entry)
+	if !ok {
+		return nil
+	}
+	diff := vault.DiffField("type", existing.Type, e.Type)
+	diff = append(diff, vault.DiffField("description", existing.Description, e.Description)...)
+	diff = append(diff, vault.DiffOptions(existing.ambiguousOptions(), e.ambiguousOptions())...)
+	return append(diff, vault.DiffOptions(existing.Tune, e.Tune)...)
+}
+
+func (e entry) Describe() map[string]interface{} {
+	return map[string]interface{}{
+		"path":        e.Path,
+		"type":        e.Type,
+		"description": e.Description,
+		"options":     e.Options,
+		"tune":        e.Tune,
+	}
+}
+
+// mountConfig returns the tune payload of the entry, excluding max_versions
+func (e entry) mountConfig() (api.MountConfigInput, error) {
+	tune := make(map[string]interface{}, len(e.Tune))
+	for k, v := range e.Tune {
+		if k != maxVersions {
+			tune[k] = v
+		}
+	}
+	config, err := vault.MountConfigInput(tune)
+	if err != nil {
+		return config, fmt.Errorf("[Vault Secrets engine] invalid tune for `%s`: %w", e.Path, err)
+	}
+	return config, nil
+}
+
 func (e entry) ambiguousOptions() map[string]interface{} {
 	opts := make(map[string]interface{}, len(e.Options))
 	for k, v := range e.Options {
@@ -71,16 +140,18 @@ func init() {
 
 // TODO(dwelch) refactor into multiple functions
 // Apply ensures that an instance of Vault's secrets engine are configured
-// exactly as provided.
-func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPoolSize int) error {
+// exactly as provided. Engines are enabled, updated and configured in the write
+// phase and disabled in the delete phase.
+func (c config) Apply(ctx context.Context, phase toplevel.Phase, address string, entriesBytes []byte, dryRun bool, threadPoolSize int, plan *toplevel.Plan) error {
 	// Unmarshal the list of configured secrets engines.
 	var entries []entry
 	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
-		log.WithError(err).Fatal("[Vault Secrets engine] failed to decode secrets engines configuration")
+		return vault.NewConfigError("[Vault Secrets engine] failed to decode secrets engines configuration", err)
 	}
 
 	instancesToDesiredEngines := make(map[string][]entry)
 	for _, e := range entries {
+		e.Tune = vault.WithoutNilOptions(e.Tune)
 		instancesToDesiredEngines[e.Instance.Address] = append(instancesToDesiredEngines[e.Instance.Address], e)
 	}
 
@@ -88,25 +159,69 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 		func(e entry) string {
 			return e.Key()
 		}); !unique {
-		return fmt.Errorf("Duplicate key value detected within %s", toplevelName)
+		return vault.NewConfigError(fmt.Sprintf("[%s] %s", address, toplevelName), errors.New("duplicate key value detected"))
 	}
 
-	enabledSecretEngines, err := vault.ListSecretsEngines(address)
+	enabledSecretEngines, err := vault.ListSecretsEngines(ctx, address)
 	if err != nil {
 		return err
 	}
 
+	desiredTunes := make(map[string]map[string]interface{})
+	for _, e := range instancesToDesiredEngines[address] {
+		desiredTunes[strings.Trim(e.Path, "/")] = e.Tune
+	}
+
 	existingSecretEngines := []entry{}
 	for path, engine := range enabledSecretEngines {
+		tune, err := getExistingTune(ctx, address, path, engine, desiredTunes[strings.Trim(path, "/")])
+		if err != nil {
+			return err
+		}
 		existingSecretEngines = append(existingSecretEngines, entry{
 			Path:        path,
 			Type:        engine.Type,
 			Description: engine.Description,
 			Options:     engine.Options,
+			Tune:        tune,
 		})
 	}
+	// secrets engines that are not owned by vault-manager are left untouched
+	instancesToDesiredEngines[address], existingSecretEngines =
+		toplevel.Owned(address, toplevelName, instancesToDesiredEngines[address], existingSecretEngines)
+	instancesToDesiredEngines[address] = toplevel.IgnoreChanges(instancesToDesiredEngines[address], existingSecretEngines)
 	toBeWritten, toBeDeleted, toBeUpdated :=
 		vault.DiffItems(asItems(instancesToDesiredEngines[address]), asItems(existingSecretEngines))
+	toBeWritten, toBeUpdated, err = separateUpdates(address, toBeWritten, toBeUpdated, existingSecretEngines)
+	if err != nil {
+		return err
+	}
+	toBeDeleted = withoutDefaultMounts(toBeDeleted)
+	err = toplevel.CheckDeletions(address, toplevelName, len(toBeDeleted), len(withoutDefaultMounts(asItems(existingSecretEngines))))
+	if err != nil {
+		return err
+	}
+	err = toplevel.CheckDestroys(address, toplevelName, instancesToDesiredEngines[address], toBeDeleted)
+	if err != nil {
+		return err
+	}
+	// approle credentials within kv engines only planned to be enabled are planned during a dry run
+	for _, w := range toBeWritten {
+		plan.Overlay().Record(toplevel.SecretEngineRef, address, w.Key(), toplevel.Planned{
+			Action:  toplevel.ActionWrite,
+			Type:    w.(entry).Type,
+			Options: w.(entry).Options,
+		})
+	}
+	plan.Overlay().RecordItems(toplevel.SecretEngineRef, address, toplevel.ActionDelete, toBeDeleted)
+
+	if phase == toplevel.PhaseDelete {
+		plan.AddItems(address, toplevelName, toplevel.ActionDelete, toBeDeleted, nil)
+		return disableEngines(ctx, address, toBeDeleted, dryRun)
+	}
+
+	plan.AddItems(address, toplevelName, toplevel.ActionWrite, toBeWritten, asItems(existingSecretEngines))
+	plan.AddItems(address, toplevelName, toplevel.ActionUpdate, toBeUpdated, asItems(existingSecretEngines))
 
 	if dryRun == true {
 		for _, w := range toBeWritten {
@@ -115,6 +230,7 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 				"type":     w.(entry).Type,
 				"instance": address,
 			}).Info("[Dry Run] [Vault Secrets engine] secrets-engine to be enabled")
+			diffDryRunOutput(address, w, existingSecretEngines)
 		}
 		for _, u := range toBeUpdated {
 			log.WithFields(log.Fields{
@@ -122,54 +238,135 @@ func (c config) Apply(address string, entriesBytes []byte, dryRun bool, threadPo
 				"type":     u.(entry).Type,
 				"instance": address,
 			}).Info("[Dry Run] [Vault Secrets engine] secrets-engine to be updated")
-		}
-		for _, d := range toBeDeleted {
-			if !isDefaultMount(d.Key()) {
-				log.WithFields(log.Fields{
-					"path":     d.Key(),
-					"type":     d.(entry).Type,
-					"instance": address,
-				}).Info("[Dry Run] [Vault Secrets engine] secrets-engine to be disabled")
-			}
+			diffDryRunOutput(address, u, existingSecretEngines)
 		}
 	} else {
-		// TODO(riuvshin): implement tuning
 		for _, e := range toBeWritten {
 			ent := e.(entry)
-			err := vault.EnableSecretsEngine(address, ent.Path, &api.MountInput{
+			config, err := ent.mountConfig()
+			if err != nil {
+				return err
+			}
+			err = vault.EnableSecretsEngine(ctx, address, ent.Path, &api.MountInput{
 				Type:        ent.Type,
 				Description: ent.Description,
 				Options:     ent.Options,
+				Config:      config,
 			})
 			if err != nil {
 				return err
 			}
+			err = writeMaxVersions(ctx, address, ent)
+			if err != nil {
+				return err
+			}
 		}
 
 		for _, e := range toBeUpdated {
 			ent := e.(entry)
-			err := vault.UpdateSecretsEngine(address, ent.Path, api.MountConfigInput{
-				// vault.UpdateSecretsEngine(ent.Path, &api.MountInput{
-				Description: &ent.Description,
-			})
+			config, err := ent.mountConfig()
+			if err != nil {
+				return err
+			}
+			config.Description = &ent.Description
+			err = vault.UpdateSecretsEngine(ctx, address, ent.Path, config)
+			if err != nil {
+				return err
+			}
+			err = writeMaxVersions(ctx, address, ent)
 			if err != nil {
 				return err
 			}
 		}
+	}
 
-		for _, e := range toBeDeleted {
-			ent := e.(entry)
-			if !isDefaultMount(ent.Path) {
-				err := vault.DisableSecretsEngine(address, ent.Path)
-				if err != nil {
-					return err
-				}
-			}
+	return configureEngines(ctx, address, instancesToDesiredEngines[address], toBeWritten, dryRun, plan)
+}
+
+// disableEngines disables secrets engines that are not desired anymore
+func disableEngines(ctx context.Context, address string, toBeDeleted []vault.Item, dryRun bool) error {
+	for _, d := range toBeDeleted {
+		if dryRun == true {
+			log.WithFields(log.Fields{
+				"path":     d.Key(),
+				"type":     d.(entry).Type,
+				"instance": address,
+			}).Info("[Dry Run] [Vault Secrets engine] secrets-engine to be disabled")
+			continue
+		}
+		err := vault.DisableSecretsEngine(ctx, address, d.(entry).Path)
+		if err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// getExistingTune returns the tune attributes of an existing mount limited to
+// the attributes declared within the desired tune block
+func getExistingTune(ctx context.Context, address, path string, engine *api.MountOutput,
+	desired map[string]interface{}) (map[string]interface{}, error) {
+	tune := vault.TuneOptions(engine.Config, desired)
+	if _, exists := desired[maxVersions]; exists {
+		config, err := vault.ReadSecret(ctx, address, filepath.Join(path, "config"), vault.KV_V1)
+		if err != nil {
+			return nil, err
+		}
+		tune[maxVersions] = config[maxVersions]
+	}
+	return tune, nil
+}
+
+// writes max_versions to the config endpoint of a kv v2 engine
+func writeMaxVersions(ctx context.Context, address string, e entry) error {
+	v, exists := e.Tune[maxVersions]
+	if !exists {
+		return nil
+	}
+	return vault.WriteSecret(ctx, address, filepath.Join(e.Path, "config"), vault.KV_V1,
+		map[string]interface{}{maxVersions: v})
+}
+
+// separateUpdates separates the desired engines to be written or updated by whether an
+// engine is already enabled at their path. Only the description and tune attributes of
+// an enabled engine are updated in place: a *vault.ConfigError is returned when the type
+// or options of enabled engines differ, as changing them requires disabling the engine,
+// which deletes every secret stored within it.
+func separateUpdates(address string, toBeWritten, toBeUpdated []vault.Item, existing []entry) ([]vault.Item, []vault.Item, error) {
+	written := make([]vault.Item, 0, len(toBeWritten))
+	updated := make([]vault.Item, 0, len(toBeUpdated))
+	changed := []string{}
+	for _, i := range append(append([]vault.Item{}, toBeWritten...), toBeUpdated...) {
+		desired := i.(entry)
+		enabled, exists := enabledAt(desired.Path, existing)
+		switch {
+		case !exists:
+			written = append(written, i)
+		case desired.Type == enabled.Type &&
+			vault.OptionsEqual(desired.ambiguousOptions(), enabled.ambiguousOptions()):
+			updated = append(updated, i)
+		default:
+			changed = append(changed, desired.Path)
+		}
+	}
+	if len(changed) > 0 {
+		return nil, nil, vault.NewConfigError(
+			fmt.Sprintf("[%s] %s cannot change the type or options of enabled secrets engines in place", address, toplevelName),
+			errors.New(strings.Join(changed, ", ")))
+	}
+	return written, updated, nil
+}
+
+// enabledAt returns the existing engine enabled at path
+func enabledAt(path string, existing []entry) (entry, bool) {
+	for _, e := range existing {
+		if vault.EqualPathNames(path, e.Path) {
+			return e, true
+		}
+	}
+	return entry{}, false
+}
+
 func isDefaultMount(path string) bool {
 	switch {
 	case strings.HasPrefix(path, "cubbyhole/"),
@@ -182,6 +379,26 @@ func isDefaultMount(path string) bool {
 	}
 }
 
+// outputs the changes between a desired secrets engine and the existing one at the same path
+func diffDryRunOutput(address string, item vault.Item, existing []entry) {
+	for _, line := range vault.DiffItem(item, asItems(existing)) {
+		log.WithFields(log.Fields{
+			"path":     item.Key(),
+			"instance": address,
+		}).Infof("[Dry Run] [Vault Secrets engine] secrets-engine diff: %s", line)
+	}
+}
+
+func withoutDefaultMounts(items []vault.Item) []vault.Item {
+	filtered := make([]vault.Item, 0, len(items))
+	for _, item := range items {
+		if !isDefaultMount(item.Key()) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
 func asItems(xs []entry) (items []vault.Item) {
 	items = make([]vault.Item, 0)
 	for _, x := range xs {
@@ -0,0 +1,69 @@
+package secretsengine
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeparateUpdates(t *testing.T) {
+	t.Parallel()
+
+	existing := []entry{
+		{Path: "app/", Type: "kv", Description: "app", Options: map[string]string{"version": "1"}},
+		{Path: "pki/", Type: "pki"},
+	}
+
+	cases := []struct {
+		description string
+		desired     entry
+		written     int
+		updated     int
+		expected    string
+	}{
+		{
+			"not enabled",
+			entry{Path: "transit/", Type: "transit"},
+			1, 0, "",
+		},
+		{
+			"description changed",
+			entry{Path: "pki/", Type: "pki", Description: "changed"},
+			0, 1, "",
+		},
+		{
+			"tune changed",
+			entry{Path: "app/", Type: "kv", Description: "app", Options: map[string]string{"version": "1"},
+				Tune: map[string]interface{}{"max_lease_ttl": "1h"}},
+			0, 1, "",
+		},
+		{
+			"type changed",
+			entry{Path: "pki/", Type: "transit"},
+			0, 0, "[https://vault] vault_secret_engines cannot change the type or options of enabled secrets engines in place: pki/",
+		},
+		{
+			"options and description changed",
+			entry{Path: "app/", Type: "kv", Description: "changed", Options: map[string]string{"version": "2"}},
+			0, 0, "[https://vault] vault_secret_engines cannot change the type or options of enabled secrets engines in place: app/",
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			toBeWritten, _, toBeUpdated := vault.DiffItems(asItems([]entry{c.desired}), asItems(existing))
+			written, updated, err := separateUpdates("https://vault", toBeWritten, toBeUpdated, existing)
+			if c.expected != "" {
+				require.EqualError(t, err, c.expected)
+				require.Equal(t, vault.ConfigErrorKind, vault.ErrorKind(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, written, c.written)
+			require.Len(t, updated, c.updated)
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package secretsengine
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	log "github.com/sirupsen/logrus"
+)
+
+// suffix of the attribute that specifies the kv version of a referenced secret
+const kvVersionSuffix = "_kv_version"
+
+// configureEngines writes the settings of each desired secrets engine to the
+// corresponding sub-paths of the mount, ex: `config/urls` or `roles/<name>` of a pki engine
+func configureEngines(ctx context.Context, instanceAddr string, entries []entry, toBeWritten []vault.Item,
+	dryRun bool, plan *toplevel.Plan) error {
+	pendingMounts := make(map[string]bool)
+	for _, w := range toBeWritten {
+		pendingMounts[w.Key()] = true
+	}
+
+	for _, e := range entries {
+		// sort sub-paths so that settings are written in a predictable order
+		names := make([]string, 0, len(e.Settings))
+		for name := range e.Settings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			path := filepath.Join(e.Path, name)
+			cfg, secretKeys, err := resolveSecretRefs(ctx, e.Settings[name])
+			if err != nil {
+				return fmt.Errorf("[Vault Secrets engine] failed to resolve settings for `%s`: %w", path, err)
+			}
+
+			// secret values are not returned by vault and are excluded from comparison
+			compared := make(map[string]interface{}, len(cfg))
+			for k, v := range cfg {
+				if !secretKeys[k] {
+					compared[k] = v
+				}
+			}
+			// settings of an engine that is not enabled yet cannot be read
+			dataExists := false
+			if !(dryRun && pendingMounts[e.Path]) {
+				dataExists, err = vault.DataInSecret(ctx, instanceAddr, compared, path, vault.KV_V1)
+				if err != nil {
+					return err
+				}
+			}
+			if dataExists {
+				continue
+			}
+
+			redacted := make(map[string]interface{}, len(cfg))
+			for k, v := range cfg {
+				if secretKeys[k] {
+					v = "<redacted>"
+				}
+				redacted[k] = v
+			}
+			plan.Add(toplevel.Change{
+				Instance: instanceAddr,
+				Toplevel: toplevelName,
+				Key:      path,
+				Type:     e.Type,
+				Action:   toplevel.ActionWrite,
+				After:    redacted,
+			})
+
+			if dryRun == true {
+				log.WithFields(log.Fields{
+					"path":     path,
+					"type":     e.Type,
+					"instance": instanceAddr,
+				}).Info("[Dry Run] [Vault Secrets engine] secrets-engine configuration to be written")
+				continue
+			}
+			err = vault.WriteSecret(ctx, instanceAddr, path, vault.KV_V1, cfg)
+			if err != nil {
+				return err
+			}
+			log.WithFields(log.Fields{
+				"path":     path,
+				"type":     e.Type,
+				"instance": instanceAddr,
+			}).Info("[Vault Secrets engine] secrets-engine successfully configured")
+		}
+	}
+	return nil
+}
+
+// resolveSecretRefs returns a copy of cfg where each secret reference, a map containing
+// `path` and `field`, is replaced by the value read from the master instance.
+// Similar to `oidc_client_secret` of oidc auth backends, the kv version of a referenced
+// secret is specified within a sibling `<key>_kv_version` attribute and defaults to kv_v2.
+// The returned set contains the keys of resolved secrets.
+func resolveSecretRefs(ctx context.Context, cfg map[string]interface{}) (map[string]interface{}, map[string]bool, error) {
+	resolved := make(map[string]interface{}, len(cfg))
+	secretKeys := make(map[string]bool)
+	for k, v := range cfg {
+		if strings.HasSuffix(k, kvVersionSuffix) {
+			// only used to obtain secret. do not include in reconcile
+			if _, isRef := secretRef(cfg[strings.TrimSuffix(k, kvVersionSuffix)]); isRef {
+				continue
+			}
+		}
+		location, isRef := secretRef(v)
+		if !isRef {
+			resolved[k] = v
+			continue
+		}
+		engineVersion, ok := cfg[k+kvVersionSuffix].(string)
+		if !ok || engineVersion == "" {
+			engineVersion = vault.KV_V2
+		}
+		secret, err := vault.GetVaultSecretField(ctx, vault.MasterAddress(),
+			fmt.Sprintf("%v", location["path"]), fmt.Sprintf("%v", location["field"]), engineVersion)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved[k] = secret
+		secretKeys[k] = true
+	}
+	return resolved, secretKeys, nil
+}
+
+// returns the location of a secret reference, if v is one
+func secretRef(v interface{}) (map[interface{}]interface{}, bool) {
+	location, ok := v.(map[interface{}]interface{})
+	if !ok {
+		return nil, false
+	}
+	_, hasPath := location["path"]
+	_, hasField := location["field"]
+	return location, hasPath && hasField
+}
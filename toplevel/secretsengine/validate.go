@@ -0,0 +1,78 @@
+package secretsengine
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+	"github.com/app-sre/vault-manager/toplevel"
+	"gopkg.in/yaml.v2"
+)
+
+var _ toplevel.Validator = config{}
+
+// Validate checks required fields, the kv version of kv engines, durations within
+// tune and settings and the kv versions of secret references within settings.
+// Every secrets engine, and kv engines as such, is declared so that references from
+// other configurations can be checked.
+func (c config) Validate(entriesBytes []byte, refs *toplevel.References) []toplevel.Problem {
+	var entries []entry
+	if err := yaml.Unmarshal(entriesBytes, &entries); err != nil {
+		return toplevel.Undecodable(toplevelName, err)
+	}
+	refs.Manages(toplevel.SecretEngineRef)
+	refs.Manages(toplevel.KVEngineRef)
+
+	problems := []toplevel.Problem{}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		p := toplevel.Problem{Toplevel: toplevelName, Instance: e.Instance.Address, Entry: e.Path}
+		problems = append(problems, p.Require("_path", e.Path)...)
+		problems = append(problems, p.Require("type", e.Type)...)
+		problems = append(problems, p.Require("instance.address", e.Instance.Address)...)
+		key := e.Instance.Address + strings.Trim(e.Path, "/")
+		if seen[key] {
+			problems = append(problems, p.Withf("duplicate secrets engine path"))
+		}
+		seen[key] = true
+		refs.Declare(toplevel.SecretEngineRef, e.Instance.Address, e.Path)
+		if e.Type == "kv" {
+			refs.Declare(toplevel.KVEngineRef, e.Instance.Address, e.Path)
+		}
+
+		if v, exists := e.Options["version"]; e.Type == "kv" && exists && v != "1" && v != "2" {
+			problems = append(problems, p.Withf("kv `version` option must be `1` or `2`, got `%s`", v))
+		}
+		for _, msg := range toplevel.InvalidDurations(e.Tune) {
+			problems = append(problems, p.Withf("tune: %s", msg))
+		}
+
+		names := make([]string, 0, len(e.Settings))
+		for name := range e.Settings {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			cfg := e.Settings[name]
+			for _, msg := range toplevel.InvalidDurations(cfg) {
+				problems = append(problems, p.Withf("settings.%s: %s", name, msg))
+			}
+			keys := make([]string, 0, len(cfg))
+			for k := range cfg {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				// the kv version of a secret reference defaults to kv_v2 when omitted
+				if _, isRef := secretRef(cfg[k]); !isRef || cfg[k+kvVersionSuffix] == nil || cfg[k+kvVersionSuffix] == "" {
+					continue
+				}
+				if version, ok := cfg[k+kvVersionSuffix].(string); !ok || !toplevel.ValidKVVersion(version) {
+					problems = append(problems, p.Withf("settings.%s: `%s` must be one of `%s` or `%s`, got `%v`",
+						name, k+kvVersionSuffix, vault.KV_V1, vault.KV_V2, cfg[k+kvVersionSuffix]))
+				}
+			}
+		}
+	}
+	return problems
+}
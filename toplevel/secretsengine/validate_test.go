@@ -0,0 +1,200 @@
+package secretsengine
+
+import (
+	"testing"
+
+	"github.com/app-sre/vault-manager/toplevel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		description string
+		engines     string
+		expected    []string
+	}{
+		{
+			"valid kv engine",
+			`
+- _path: app/
+  type: kv
+  instance:
+    address: https://vault
+  options:
+    version: "2"
+  tune:
+    max_lease_ttl: 24h
+`,
+			[]string{},
+		},
+		{
+			"tune with attributes unset by graphql",
+			`
+- _path: app/
+  type: kv
+  instance:
+    address: https://vault
+  options:
+    version: "2"
+  tune:
+    max_lease_ttl: null
+    max_versions: 5
+    passthrough_request_headers: null
+`,
+			[]string{},
+		},
+		{
+			"missing type",
+			`
+- _path: app/
+  instance:
+    address: https://vault
+`,
+			[]string{"missing required field `type`"},
+		},
+		{
+			"duplicate path",
+			`
+- _path: app/
+  type: kv
+  instance:
+    address: https://vault
+- _path: app
+  type: kv
+  instance:
+    address: https://vault
+`,
+			[]string{"duplicate secrets engine path"},
+		},
+		{
+			"invalid kv version",
+			`
+- _path: app/
+  type: kv
+  instance:
+    address: https://vault
+  options:
+    version: "3"
+`,
+			[]string{"kv `version` option must be `1` or `2`, got `3`"},
+		},
+		{
+			"version option of other engines",
+			`
+- _path: custom/
+  type: plugin
+  instance:
+    address: https://vault
+  options:
+    version: "3"
+`,
+			[]string{},
+		},
+		{
+			"empty ttl within tune",
+			`
+- _path: app/
+  type: kv
+  instance:
+    address: https://vault
+  tune:
+    default_lease_ttl: ""
+`,
+			[]string{"tune: invalid duration `` of `default_lease_ttl`: empty duration"},
+		},
+		{
+			"invalid ttl within settings",
+			`
+- _path: pki/
+  type: pki
+  instance:
+    address: https://vault
+  settings:
+    roles/app:
+      max_ttl: forever
+`,
+			[]string{"settings.roles/app: invalid duration `forever` of `max_ttl`: time: invalid duration \"forever\""},
+		},
+		{
+			"invalid ttl within settings fetched as json",
+			`
+- _path: pki/
+  type: pki
+  instance:
+    address: https://vault
+  settings: '{"roles/app": {"max_ttl": "forever"}}'
+`,
+			[]string{"settings.roles/app: invalid duration `forever` of `max_ttl`: time: invalid duration \"forever\""},
+		},
+		{
+			"invalid kv version of a secret reference",
+			`
+- _path: pki/
+  type: pki
+  instance:
+    address: https://vault
+  settings:
+    config/ca:
+      pem_bundle:
+        path: secret/pki
+        field: bundle
+      pem_bundle_kv_version: v2
+`,
+			[]string{"settings.config/ca: `pem_bundle_kv_version` must be one of `kv_v1` or `kv_v2`, got `v2`"},
+		},
+		{
+			"kv version of a secret reference defaults to kv_v2",
+			`
+- _path: pki/
+  type: pki
+  instance:
+    address: https://vault
+  settings:
+    config/ca:
+      pem_bundle:
+        path: secret/pki
+        field: bundle
+`,
+			[]string{},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.description, func(t *testing.T) {
+			t.Parallel()
+			problems := config{}.Validate([]byte(c.engines), toplevel.NewReferences())
+			messages := []string{}
+			for _, p := range problems {
+				messages = append(messages, p.Message)
+			}
+			require.Equal(t, c.expected, messages)
+		})
+	}
+}
+
+func TestValidateDeclaresKVEngines(t *testing.T) {
+	t.Parallel()
+
+	refs := toplevel.NewReferences()
+	require.Empty(t, config{}.Validate([]byte(`
+- _path: app/
+  type: kv
+  instance:
+    address: https://vault
+- _path: pki/
+  type: pki
+  instance:
+    address: https://vault
+`), refs))
+	refs.ReferenceWithin(toplevel.KVEngineRef, "app/creds", toplevel.Problem{Instance: "https://vault", Entry: "kv"})
+	refs.ReferenceWithin(toplevel.KVEngineRef, "pki/creds", toplevel.Problem{Instance: "https://vault", Entry: "pki"})
+
+	dangling := refs.Dangling()
+	require.Len(t, dangling, 1)
+	require.Equal(t, "pki", dangling[0].Entry)
+	require.Equal(t, "references `pki/creds` which is not within a kv secrets engine declared for the instance",
+		dangling[0].Message)
+}
@@ -3,34 +3,50 @@
 package toplevel
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"sync"
 
 	"github.com/app-sre/vault-manager/pkg/vault"
-	log "github.com/sirupsen/logrus"
-)
-
-type PolicyAction int
-
-const (
-	Write = iota
-	Delete
 )
 
 var (
-	configs       = make(map[string]Configuration)
-	configsM      sync.RWMutex
-	policyActions = make(map[string]PolicyAction)
+	configs  = make(map[string]Configuration)
+	configsM sync.RWMutex
 )
 
 // Configuration represents a block of declarative configuration data that can
 // be applied to a service.
 //
-// If an error occurs applying a configuration, the process should exit.
+// A configuration is applied twice to an instance, once per Phase. It only
+// creates and updates entries in the write phase and only deletes entries in the
+// delete phase. Every change computed while applying a configuration is recorded
+// in the provided Plan, within the phase it is made in.
+//
+// Every request to Vault made while applying a configuration is bound by the
+// provided context, which is cancelled once the instance deadline is exceeded
+// or the process is asked to terminate.
+//
+// Errors applying a configuration are returned rather than exiting the process,
+// typed by the vault package, ex: *vault.ConfigError or *vault.APIError, so that
+// the instance is marked failed while other instances keep being reconciled.
 type Configuration interface {
-	Apply(string, []byte, bool, int) error
+	Apply(context.Context, Phase, string, []byte, bool, int, *Plan) error
 }
 
+// Phase is a phase of the reconcile of an instance. Every configuration is
+// applied in the write phase, in order of priority, before every configuration is
+// applied again in the delete phase, in reverse order of priority. Entries are
+// then only deleted once the entries referencing them were updated, ex: a renamed
+// policy is only deleted once roles and groups reference the new name.
+type Phase string
+
+const (
+	PhaseWrite  Phase = "write"
+	PhaseDelete Phase = "delete"
+)
+
 // RegisterConfiguration makes a Configuration available by the provided name.
 //
 // If called twice with the same name, the name is blank, or if the provided
@@ -56,45 +72,62 @@ func RegisterConfiguration(name string, c Configuration) {
 	configs[name] = c
 }
 
-// Apply looks up registered top-level configuration by name and applies it an
-// instance of Vault.
-func Apply(name string, address string, cfg []byte, dryRun bool, threadPoolSize int) error {
+// Includer is implemented by configurations whose desired state spans several top-level
+// keys, ex: groups declared within `vault_declared_groups` are reconciled alongside the
+// groups derived from user roles within `vault_groups`. Included keys are not applied
+// on their own: the configuration is applied once to the entries of its own key and of
+// every included key, marshalled as a mapping of the keys to their entries.
+type Includer interface {
+	Includes() []string
+}
+
+// Keys returns the top-level keys of the desired state of the configuration registered
+// by name: its name, followed by the keys it includes
+func Keys(name string) []string {
 	configsM.RLock()
 	defer configsM.RUnlock()
-	c, ok := configs[name]
-	if !ok {
-		log.WithField("name", name).Fatal("failed to find top-level configuration")
+	keys := []string{name}
+	if i, ok := configs[name].(Includer); ok {
+		keys = append(keys, i.Includes()...)
 	}
-	return c.Apply(address, cfg, dryRun, threadPoolSize)
+	return keys
 }
 
-// Update package level policies which are to be written or deleted
-func UpdatePolicies(toBeWritten []vault.Item, toBeDeleted []vault.Item) {
-	for _, w := range toBeWritten {
-		policyActions[w.Key()] = Write
-	}
-
-	for _, d := range toBeDeleted {
-		policyActions[d.Key()] = Delete
+// IncludedBy returns the name of the configuration whose desired state includes the
+// entries of key, or key itself when it is not included by any configuration
+func IncludedBy(key string) string {
+	configsM.RLock()
+	defer configsM.RUnlock()
+	for name, c := range configs {
+		if i, ok := c.(Includer); ok {
+			for _, included := range i.Includes() {
+				if included == key {
+					return name
+				}
+			}
+		}
 	}
+	return key
 }
 
-// Return the list of policy actions
-func GetPolicies() map[string]PolicyAction {
-	return policyActions
-}
-
-// Clear list of policy actions
-func ClearPolicies() {
-	policyActions = make(map[string]PolicyAction)
+// Apply looks up registered top-level configuration by name and applies it an
+// instance of Vault within phase.
+func Apply(ctx context.Context, phase Phase, name string, address string, cfg []byte, dryRun bool, threadPoolSize int, plan *Plan) error {
+	configsM.RLock()
+	defer configsM.RUnlock()
+	c, ok := configs[name]
+	if !ok {
+		return vault.NewConfigError("failed to find top-level configuration", fmt.Errorf("`%s`", name))
+	}
+	return c.Apply(ctx, phase, address, cfg, dryRun, threadPoolSize, plan)
 }
 
 // Output policy actions in string format
-func PrintPolicyAction(policyAction PolicyAction) string {
-	switch policyAction {
-	case Write:
+func PrintPolicyAction(action Action) string {
+	switch action {
+	case ActionWrite, ActionUpdate:
 		return "updated"
-	case Delete:
+	case ActionDelete:
 		return "removed"
 	}
 	return "invalid"
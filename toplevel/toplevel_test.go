@@ -0,0 +1,37 @@
+package toplevel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type includingConfig struct{}
+
+func (includingConfig) Apply(context.Context, Phase, string, []byte, bool, int, *Plan) error {
+	return nil
+}
+
+func (includingConfig) Includes() []string {
+	return []string{"vault_included"}
+}
+
+func init() {
+	RegisterConfiguration("vault_including", includingConfig{})
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, []string{"vault_including", "vault_included"}, Keys("vault_including"))
+	require.Equal(t, []string{"vault_budgeted"}, Keys("vault_budgeted"))
+}
+
+func TestIncludedBy(t *testing.T) {
+	t.Parallel()
+
+	require.Equal(t, "vault_including", IncludedBy("vault_included"))
+	require.Equal(t, "vault_including", IncludedBy("vault_including"))
+	require.Equal(t, "vault_unknown", IncludedBy("vault_unknown"))
+}
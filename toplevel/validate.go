@@ -0,0 +1,279 @@
+package toplevel
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/app-sre/vault-manager/pkg/vault"
+)
+
+// Validator is implemented by configurations that validate their entries before
+// any instance is reconciled. Entries declared and referenced by a configuration
+// are recorded within refs so that dangling references are reported across
+// top-level configurations.
+type Validator interface {
+	Validate(cfg []byte, refs *References) []Problem
+}
+
+// Problem describes an invalid entry of the desired state
+type Problem struct {
+	Toplevel string
+	Instance string
+	// identifies the entry within its top-level configuration, ex: its name or path
+	Entry   string
+	Message string
+}
+
+func (p Problem) Error() string {
+	return fmt.Sprintf("[%s] instance=%s entry=`%s`: %s", p.Toplevel, p.Instance, p.Entry, p.Message)
+}
+
+// Withf returns a copy of the problem with a formatted message
+func (p Problem) Withf(format string, args ...interface{}) Problem {
+	p.Message = fmt.Sprintf(format, args...)
+	return p
+}
+
+// Require returns a problem when value, the value of a required field, is empty
+func (p Problem) Require(field, value string) []Problem {
+	if value != "" {
+		return nil
+	}
+	return []Problem{p.Withf("missing required field `%s`", field)}
+}
+
+// Undecodable returns the problem of a configuration that could not be decoded
+func Undecodable(name string, err error) []Problem {
+	return []Problem{{Toplevel: name, Message: fmt.Sprintf("failed to decode configuration: %v", err)}}
+}
+
+// ValidationError reports every problem found within the desired state
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, p.Error())
+	}
+	return fmt.Sprintf("%d problem(s) found: %s", len(e.Problems), strings.Join(msgs, "; "))
+}
+
+// ReferenceMode determines how dangling references between entries are reported
+type ReferenceMode string
+
+const (
+	// dangling references are problems failing the validation
+	ReferencesFail ReferenceMode = "fail"
+	// dangling references are only returned as warnings
+	ReferencesWarn ReferenceMode = "warn"
+)
+
+// ParseReferenceMode returns the mode named s
+func ParseReferenceMode(s string) (ReferenceMode, error) {
+	switch mode := ReferenceMode(s); mode {
+	case ReferencesFail, ReferencesWarn:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unsupported reference mode %q, must be `%s` or `%s`", s, ReferencesFail, ReferencesWarn)
+	}
+}
+
+// Validate validates the entries of every top-level configuration, mapped by name,
+// as well as the references between them. All problems are reported at once
+// within a *ValidationError. Dangling references are returned as warnings instead
+// when mode is ReferencesWarn. No instance is accessed.
+func Validate(cfgs map[string][]byte, mode ReferenceMode) ([]Problem, error) {
+	configsM.RLock()
+	defer configsM.RUnlock()
+
+	names := make([]string, 0, len(cfgs))
+	for name := range cfgs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	problems := []Problem{}
+	refs := NewReferences()
+	for _, name := range names {
+		c, ok := configs[name]
+		if !ok {
+			problems = append(problems, Problem{Toplevel: name, Message: "unknown top-level configuration"})
+			continue
+		}
+		if v, ok := c.(Validator); ok {
+			problems = append(problems, v.Validate(cfgs[name], refs)...)
+		}
+	}
+	warnings := []Problem{}
+	if mode == ReferencesWarn {
+		warnings = refs.Dangling()
+	} else {
+		problems = append(problems, refs.Dangling()...)
+	}
+
+	if len(problems) > 0 {
+		return warnings, &ValidationError{Problems: problems}
+	}
+	return warnings, nil
+}
+
+// ReferenceKind is a kind of entry that may be referenced by other entries
+type ReferenceKind string
+
+const (
+	PolicyRef       ReferenceKind = "policy"
+	AuthBackendRef  ReferenceKind = "auth backend"
+	SecretEngineRef ReferenceKind = "secrets engine"
+	KVEngineRef     ReferenceKind = "kv secrets engine"
+)
+
+// entries that exist on every instance without being declared
+var builtinRefs = map[ReferenceKind][]string{
+	PolicyRef:       {"default", "root"},
+	AuthBackendRef:  {"token"},
+	SecretEngineRef: {"cubbyhole", "identity", "sys"},
+}
+
+// References records the entries declared per instance and the references to
+// them. Only references to kinds managed by a configuration of the desired state
+// are checked, as entries of other kinds are not reconciled.
+type References struct {
+	managed  map[ReferenceKind]bool
+	declared map[ReferenceKind]map[string]bool
+	refs     []reference
+}
+
+type reference struct {
+	kind ReferenceKind
+	name string
+	// the reference is a path within a declared entry, ex: a secret within a kv engine
+	within bool
+	from   Problem
+}
+
+func NewReferences() *References {
+	return &References{
+		managed:  make(map[ReferenceKind]bool),
+		declared: make(map[ReferenceKind]map[string]bool),
+	}
+}
+
+// Manages marks a kind as managed by a configuration of the desired state
+func (r *References) Manages(kind ReferenceKind) {
+	r.managed[kind] = true
+}
+
+// Declare records that an entry of a kind is declared for an instance
+func (r *References) Declare(kind ReferenceKind, instance, name string) {
+	if r.declared[kind] == nil {
+		r.declared[kind] = make(map[string]bool)
+	}
+	r.declared[kind][refKey(instance, name)] = true
+}
+
+// Reference records that from, an entry of an instance, references an entry of a kind
+func (r *References) Reference(kind ReferenceKind, name string, from Problem) {
+	r.refs = append(r.refs, reference{kind: kind, name: name, from: from})
+}
+
+// ReferenceWithin records that from, an entry of an instance, references path within
+// an entry of a kind, ex: a secret path within a secrets engine
+func (r *References) ReferenceWithin(kind ReferenceKind, path string, from Problem) {
+	r.refs = append(r.refs, reference{kind: kind, name: path, within: true, from: from})
+}
+
+// Dangling returns a problem for each reference to an entry that is not declared
+// for the instance of the referencing entry
+func (r *References) Dangling() []Problem {
+	problems := []Problem{}
+	// an entry may reference the same entry several times, ex: a group referenced by many users
+	reported := make(map[string]bool)
+	for _, ref := range r.refs {
+		if !r.managed[ref.kind] || ref.from.Instance == "" || r.isDeclared(ref) {
+			continue
+		}
+		p := ref.from
+		if ref.within {
+			p.Message = fmt.Sprintf("references `%s` which is not within a %s declared for the instance", ref.name, ref.kind)
+		} else {
+			p.Message = fmt.Sprintf("references %s `%s` which is not declared for the instance", ref.kind, ref.name)
+		}
+		if reported[p.Error()] {
+			continue
+		}
+		reported[p.Error()] = true
+		problems = append(problems, p)
+	}
+	return problems
+}
+
+func (r *References) isDeclared(ref reference) bool {
+	if !ref.within {
+		return r.declared[ref.kind][refKey(ref.from.Instance, ref.name)] || isBuiltin(ref.kind, ref.name)
+	}
+	// any parent path of a path may be the path of the declared entry
+	segments := strings.Split(strings.Trim(ref.name, "/"), "/")
+	for i := len(segments) - 1; i > 0; i-- {
+		parent := strings.Join(segments[:i], "/")
+		if r.declared[ref.kind][refKey(ref.from.Instance, parent)] || isBuiltin(ref.kind, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+func isBuiltin(kind ReferenceKind, name string) bool {
+	for _, b := range builtinRefs[kind] {
+		if b == strings.Trim(name, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// mount paths are compared regardless of leading or trailing slashes
+func refKey(instance, name string) string {
+	return instance + "|" + strings.Trim(name, "/")
+}
+
+// InvalidDurations returns a message for each option holding a ttl or period
+// that can not be parsed as a duration
+func InvalidDurations(options map[string]interface{}) []string {
+	keys := make([]string, 0, len(options))
+	for k := range options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	msgs := []string{}
+	for _, k := range keys {
+		v := options[k]
+		if v == nil || !(strings.HasSuffix(k, "ttl") || strings.HasSuffix(k, "period")) {
+			continue
+		}
+		if err := validDuration(v); err != nil {
+			msgs = append(msgs, fmt.Sprintf("invalid duration `%v` of `%s`: %v", v, k, err))
+		}
+	}
+	return msgs
+}
+
+func validDuration(v interface{}) error {
+	switch d := v.(type) {
+	case int, int64, float64:
+		return nil
+	case string:
+		_, err := vault.ParseDuration(d)
+		return err
+	default:
+		return fmt.Errorf("unexpected type %T", v)
+	}
+}
+
+// ValidKVVersion determines if v is a kv version accepted for secret references
+func ValidKVVersion(v string) bool {
+	return v == vault.KV_V1 || v == vault.KV_V2
+}
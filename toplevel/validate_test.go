@@ -0,0 +1,78 @@
+package toplevel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferencesDangling(t *testing.T) {
+	t.Parallel()
+
+	from := Problem{Toplevel: "vault_roles", Instance: "https://a", Entry: "role"}
+	refs := NewReferences()
+	refs.Manages(PolicyRef)
+	refs.Declare(PolicyRef, "https://a", "declared")
+	refs.Declare(PolicyRef, "https://b", "other-instance")
+
+	refs.Reference(PolicyRef, "declared", from)
+	refs.Reference(PolicyRef, "default", from)
+	refs.Reference(PolicyRef, "other-instance", from)
+	refs.Reference(PolicyRef, "other-instance", from)
+	// auth backends are not managed by the desired state
+	refs.Reference(AuthBackendRef, "oidc/", from)
+
+	dangling := refs.Dangling()
+	require.Len(t, dangling, 1)
+	require.Equal(t, "[vault_roles] instance=https://a entry=`role`: "+
+		"references policy `other-instance` which is not declared for the instance", dangling[0].Error())
+}
+
+func TestReferencesWithin(t *testing.T) {
+	t.Parallel()
+
+	from := Problem{Toplevel: "vault_roles", Instance: "https://a", Entry: "role"}
+	refs := NewReferences()
+	refs.Manages(KVEngineRef)
+	refs.Declare(KVEngineRef, "https://a", "app/kv/")
+
+	refs.ReferenceWithin(KVEngineRef, "app/kv/creds", from)
+	refs.ReferenceWithin(KVEngineRef, "app/other/creds", from)
+	refs.ReferenceWithin(KVEngineRef, "app/kv", from)
+
+	dangling := refs.Dangling()
+	require.Len(t, dangling, 2)
+	require.Equal(t, "references `app/other/creds` which is not within a kv secrets engine declared for the instance",
+		dangling[0].Message)
+	require.Equal(t, "references `app/kv` which is not within a kv secrets engine declared for the instance",
+		dangling[1].Message)
+}
+
+func TestInvalidDurations(t *testing.T) {
+	t.Parallel()
+
+	msgs := InvalidDurations(map[string]interface{}{
+		"token_ttl":     "1h",
+		"token_max_ttl": 60,
+		"token_period":  "",
+		"secret_id_ttl": "forever",
+		"unset_ttl":     nil,
+		"description":   "",
+	})
+	require.Equal(t, []string{
+		"invalid duration `forever` of `secret_id_ttl`: time: invalid duration \"forever\"",
+		"invalid duration `` of `token_period`: empty duration",
+	}, msgs)
+}
+
+func TestValidateUnknownConfiguration(t *testing.T) {
+	t.Parallel()
+
+	warnings, err := Validate(map[string][]byte{"vault_unknown": []byte("[]")}, ReferencesFail)
+	require.Empty(t, warnings)
+	require.Error(t, err)
+	validationErr, ok := err.(*ValidationError)
+	require.True(t, ok)
+	require.Equal(t, []Problem{{Toplevel: "vault_unknown", Message: "unknown top-level configuration"}},
+		validationErr.Problems)
+}